@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/imdraw"
+	"github.com/gopxl/pixel/pixelgl"
+	"github.com/gopxl/pixel/text"
+	"github.com/gorilla/websocket"
+)
+
+type AbilityStatus struct {
+	Name     string  `json:"name"`
+	Input    string  `json:"input"`
+	Cooldown float64 `json:"cooldown"`
+	ReadyIn  float64 `json:"readyIn"`
+}
+
+type abilitySlot struct {
+	AbilityStatus
+	readyAt time.Time
+}
+
+var abilities []abilitySlot
+var abmu sync.Mutex
+var abilityImd = imdraw.New(nil)
+
+// Keys for the abilities besides the primary attack, which stays on the left
+// mouse button in the game loop
+var abilityButtons = []struct {
+	input  string
+	button pixelgl.Button
+}{
+	{"secondary", pixelgl.MouseButtonRight},
+	{"shift", pixelgl.KeyLeftShift},
+	{"q", pixelgl.KeyQ},
+}
+
+var inputLabels = map[string]string{"primary": "LMB", "secondary": "RMB", "shift": "Shift", "q": "Q"}
+
+const abilityIconSize = 64.0
+
+func handleAbilityState(msg Message) {
+	var statuses []AbilityStatus
+	data, err := json.Marshal(msg.Content)
+	if err != nil {
+		log.Printf("Error marshaling ability state: %v", err)
+		return
+	}
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		log.Printf("Error unmarshaling ability state: %v", err)
+		return
+	}
+	now := time.Now()
+	abmu.Lock()
+	abilities = abilities[:0]
+	for _, status := range statuses {
+		readyAt := now.Add(time.Duration(status.ReadyIn * float64(time.Second)))
+		abilities = append(abilities, abilitySlot{AbilityStatus: status, readyAt: readyAt})
+	}
+	abmu.Unlock()
+}
+
+// sendAbilityInputs casts the abilities whose keys were just pressed, aimed
+// at the mouse.
+func sendAbilityInputs(win *pixelgl.Window, conn *websocket.Conn, player *Player) error {
+	for _, b := range abilityButtons {
+		if !win.JustPressed(b.button) {
+			continue
+		}
+		msg := Message{
+			ClientID: playerID,
+			Type:     "player_ability",
+			Content: PlayerState{
+				"id":         player.ID,
+				"input":      b.input,
+				"directionX": player.aim.X,
+				"directionY": player.aim.Y,
+				"viewTick":   lastSnapshotTick,
+			},
+		}
+		if err := conn.WriteJSON(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DrawAbilityHUD draws a row of ability icons at the bottom of the screen,
+// darkened while they're on cooldown.
+func DrawAbilityHUD(win *pixelgl.Window) {
+	abmu.Lock()
+	defer abmu.Unlock()
+	if len(abilities) == 0 {
+		return
+	}
+	const gap = 10.0
+	width := float64(len(abilities))*(abilityIconSize+gap) - gap
+	x := win.Bounds().W()/2 - width/2
+	y := 20.0
+
+	abilityImd.Clear()
+	var labels []*text.Text
+	for _, ability := range abilities {
+		rect := pixel.R(x, y, x+abilityIconSize, y+abilityIconSize)
+		readyIn := math.Max(0, time.Until(ability.readyAt).Seconds())
+
+		abilityImd.Color = pixel.RGB(0.3, 0.3, 0.5)
+		abilityImd.Push(rect.Min, rect.Max)
+		abilityImd.Rectangle(0)
+		if readyIn > 0 && ability.Cooldown > 0 {
+			// Shade the part of the cooldown that's left, from the top down
+			left := math.Min(1, readyIn/ability.Cooldown)
+			abilityImd.Color = pixel.RGBA{R: 0, G: 0, B: 0, A: 0.7}
+			abilityImd.Push(pixel.V(rect.Min.X, rect.Max.Y-rect.H()*left), rect.Max)
+			abilityImd.Rectangle(0)
+		}
+		abilityImd.Color = pixel.RGB(1, 1, 1)
+		abilityImd.Push(rect.Min, rect.Max)
+		abilityImd.Rectangle(1)
+
+		txt := text.New(pixel.V(rect.Min.X+4, rect.Max.Y-14), hudAtlas)
+		txt.Color = pixel.RGB(1, 1, 1)
+		fmt.Fprintln(txt, inputLabels[ability.Input])
+		fmt.Fprintln(txt, ability.Name)
+		if readyIn > 0 {
+			fmt.Fprintf(txt, "%.1f", readyIn)
+		}
+		labels = append(labels, txt)
+		x += abilityIconSize + gap
+	}
+	abilityImd.Draw(win)
+	for _, txt := range labels {
+		txt.Draw(win, pixel.IM)
+	}
+}
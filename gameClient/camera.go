@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/imdraw"
+	"github.com/gopxl/pixel/pixelgl"
+)
+
+// Arena size sent by the server on join, the window only shows part of it.
+var worldBounds = pixel.R(0, 0, 1152, 864)
+
+var worldImd = imdraw.New(nil)
+
+// cameraMatrix keeps target in the middle of the window. Everything drawn in
+// world coordinates goes through it, HUD elements use pixel.IM.
+func cameraMatrix(win *pixelgl.Window, target pixel.Vec) pixel.Matrix {
+	return pixel.IM.Moved(win.Bounds().Center().Sub(target))
+}
+
+// DrawWorld fills the arena so its edges are visible against the background.
+func DrawWorld(win *pixelgl.Window) {
+	worldImd.Clear()
+	worldImd.Color = pixel.RGB(0, 0.5, 0.4)
+	worldImd.Push(worldBounds.Min, worldBounds.Max)
+	worldImd.Rectangle(0)
+	worldImd.Draw(win)
+}
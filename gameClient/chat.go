@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/pixelgl"
+	"github.com/gopxl/pixel/text"
+	"github.com/gorilla/websocket"
+)
+
+type ChatMessage struct {
+	From     int    `json:"from"`
+	Nickname string `json:"nickname"`
+	Scope    string `json:"scope"`
+	To       string `json:"to"`
+	ToID     int    `json:"toId"`
+	Text     string `json:"text"`
+}
+
+type chatLine struct {
+	text     string
+	color    pixel.RGBA
+	received time.Time
+}
+
+const (
+	chatMaxLength = 200 // same as the server's default
+	chatLogSize   = 8
+	chatShowTime  = 6 * time.Second // fully visible before fading
+	chatFadeTime  = 4 * time.Second
+)
+
+var (
+	chatOpen  bool
+	chatInput string
+	chatLog   []chatLine
+	chatmu    sync.Mutex
+)
+
+func handleChatMessage(msg Message) {
+	var chat ChatMessage
+	data, err := json.Marshal(msg.Content)
+	if err != nil {
+		log.Printf("Error marshaling chat: %v", err)
+		return
+	}
+	if err := json.Unmarshal(data, &chat); err != nil {
+		log.Printf("Error unmarshaling chat: %v", err)
+		return
+	}
+
+	line := chatLine{color: pixel.RGB(1, 1, 1), received: time.Now()}
+	switch {
+	case chat.From == 0:
+		line.text = chat.Text
+		line.color = pixel.RGB(1, 1, 0)
+	case chat.Scope == "team":
+		line.text = fmt.Sprintf("[Team] %s #%d: %s", chat.Nickname, chat.From, chat.Text)
+		line.color = pixel.RGB(0.4, 1, 0.4)
+	case chat.Scope == "whisper" && chat.From == playerID:
+		line.text = fmt.Sprintf("To %s #%d: %s", chat.To, chat.ToID, chat.Text)
+		line.color = pixel.RGB(1, 0.5, 1)
+	case chat.Scope == "whisper":
+		line.text = fmt.Sprintf("From %s #%d: %s", chat.Nickname, chat.From, chat.Text)
+		line.color = pixel.RGB(1, 0.5, 1)
+	default:
+		line.text = fmt.Sprintf("%s #%d: %s", chat.Nickname, chat.From, chat.Text)
+	}
+	addChatLine(line)
+}
+
+// addChatLine adds a line to the log, dropping the oldest.
+func addChatLine(line chatLine) {
+	chatmu.Lock()
+	chatLog = append(chatLog, line)
+	if len(chatLog) > chatLogSize {
+		chatLog = chatLog[len(chatLog)-chatLogSize:]
+	}
+	chatmu.Unlock()
+}
+
+// parseChat turns what was typed into a chat_send. "/t text" goes to the
+// team, "/w 12 text" whispers to the player shown as #12, anything else is
+// global. Returns nil if the whisper target isn't a number.
+func parseChat(input string) map[string]interface{} {
+	switch {
+	case strings.HasPrefix(input, "/t "):
+		return map[string]interface{}{"scope": "team", "text": input[3:]}
+	case strings.HasPrefix(input, "/w "):
+		to, text, _ := strings.Cut(input[3:], " ")
+		id, err := strconv.Atoi(strings.TrimPrefix(to, "#"))
+		if err != nil {
+			return nil
+		}
+		return map[string]interface{}{"scope": "whisper", "to": id, "text": text}
+	default:
+		return map[string]interface{}{"scope": "global", "text": input}
+	}
+}
+
+// updateChatInput opens the chat with Enter and handles typing while it's
+// open. Returns true while the chat has the keyboard.
+func updateChatInput(win *pixelgl.Window, conn *websocket.Conn) (bool, error) {
+	if !chatOpen {
+		if win.JustPressed(pixelgl.KeyEnter) {
+			chatOpen = true
+			win.Typed() // drop anything typed before
+			return true, nil
+		}
+		return false, nil
+	}
+
+	switch {
+	case win.JustPressed(pixelgl.KeyEscape):
+		chatOpen, chatInput = false, ""
+	case win.JustPressed(pixelgl.KeyEnter):
+		input := strings.TrimSpace(chatInput)
+		chatOpen, chatInput = false, ""
+		if input == "" {
+			break
+		}
+		send := parseChat(input)
+		if send == nil {
+			addChatLine(chatLine{text: "Whisper with /w <player number> <text>", color: pixel.RGB(1, 1, 0), received: time.Now()})
+			break
+		}
+		return true, conn.WriteJSON(Message{ClientID: playerID, Type: "chat_send", Content: send})
+	case win.JustPressed(pixelgl.KeyBackspace) || win.Repeated(pixelgl.KeyBackspace):
+		if len(chatInput) > 0 {
+			chatInput = chatInput[:len(chatInput)-1]
+		}
+	default:
+		chatInput += win.Typed()
+		if len(chatInput) > chatMaxLength {
+			chatInput = chatInput[:chatMaxLength]
+		}
+	}
+	return true, nil
+}
+
+// DrawChat shows recent messages in the bottom left, fading out unless the
+// chat is open, and the input line while typing.
+func DrawChat(win *pixelgl.Window) {
+	now := time.Now()
+	y := 60.0
+	if chatOpen {
+		input := text.New(pixel.V(10, y), hudAtlas)
+		input.Color = pixel.RGB(1, 1, 1)
+		fmt.Fprintf(input, "> %s_", chatInput)
+		input.Draw(win, pixel.IM)
+	}
+
+	chatmu.Lock()
+	defer chatmu.Unlock()
+	for i := len(chatLog) - 1; i >= 0; i-- {
+		line := chatLog[i]
+		y += hudAtlas.LineHeight() + 2
+		alpha := 1.0
+		if age := now.Sub(line.received); !chatOpen && age > chatShowTime {
+			alpha = 1 - float64(age-chatShowTime)/float64(chatFadeTime)
+		}
+		if alpha <= 0 {
+			continue
+		}
+		txt := text.New(pixel.V(10, y), hudAtlas)
+		txt.Color = line.color.Mul(pixel.Alpha(alpha))
+		fmt.Fprint(txt, line.text)
+		txt.Draw(win, pixel.IM)
+	}
+}
@@ -153,16 +153,28 @@ func run() {
 
 }
 func startGame(win *pixelgl.Window, conn *websocket.Conn) {
+	win.SetMatrix(pixel.IM) // menus are drawn in screen coordinates
 	nickname, heroClass := createPlayerForm(win)
+	if heroClass == choiceEditor {
+		runEditor(win)
+		return
+	}
+	if heroClass == choiceSpectate {
+		runSpectator(win, conn)
+		return
+	}
 	if nickname == "" || heroClass == 0 {
 		return // Exit if the form was closed without completing
 	}
+	playerClass = heroClass
 	var playerData struct {
 		HeroClass int    `json:"heroClass"`
 		Nickname  string `json:"nickname"`
+		Key       string `json:"key"`
 	}
 	playerData.HeroClass = heroClass
 	playerData.Nickname = nickname
+	playerData.Key = playerKey()
 	msg := Message{
 
 		Type:    "new_player",
@@ -175,101 +187,125 @@ func startGame(win *pixelgl.Window, conn *websocket.Conn) {
 	if err != nil {
 		log.Println("new player write:", err)
 	}
-	n := 4000
-	for !playerExists {
-		// Handle incoming messages
-		select {
-		case msg := <-receive:
-			HandleMessage(msg, nil)
-		default:
-			// Continue if no message
-		}
-		time.Sleep(time.Second / 600)
-		n--
-		if n == 0 {
-			log.Println("Timed out waiting for player")
-			break
+	for runMatchScreens(win, conn, msg) {
+		if !playGame(win, conn, nickname) {
+			return
 		}
 	}
-	if !playerExists {
-		return
-	}
-	//  waitPlayer()
-	player := NewPlayer(pixel.V(startX, startY), win.Bounds(), nickname, playerClass)
+}
+
+// playGame runs the game loop until our player dies or the match ends, then
+// returns true to go back to the match screens. Returns false if the player
+// quit to the main menu.
+func playGame(win *pixelgl.Window, conn *websocket.Conn, nickname string) bool {
+	pendingInputs = nil
+	player := NewPlayer(pixel.V(startX, startY), worldBounds, nickname, playerClass)
 	player.ID = playerID
 	// Game state variables
 	lastTime := time.Now()
 
 	stateTicker := time.NewTicker(time.Second / 20)
 	defer stateTicker.Stop()
+	pingTicker := time.NewTicker(pingInterval)
+	defer pingTicker.Stop()
+	if err := sendPing(conn); err != nil {
+		log.Println("ping write:", err)
+	}
 
 	// Main game loop
 	for !win.Closed() {
 		// fps
 		time.Sleep(time.Second / fps)
-		if stopPlaying || win.Pressed(pixelgl.KeyEscape) {
+		typing, err := updateChatInput(win, conn)
+		if err != nil {
+			log.Println("chat write:", err)
+			return false
+		}
+		if !typing && win.JustPressed(pixelgl.KeyEscape) {
+			return false
+		}
+		if stopPlaying {
 			log.Println("You are dead")
 			// os.Exit(1)
 			stopPlaying = false
-			startGame(win, conn)
-			break
+			return true
+		}
+		if info, _ := currentMatch(); info.State != MatchPlaying {
+			return true
 		}
 		// Calculate delta time
 		currentTime := time.Now()
 		dt = currentTime.Sub(lastTime).Seconds()
 		lastTime = currentTime
 
-		win.Clear(pixel.RGB(0, 0.5, 0.4))
+		win.Clear(pixel.RGB(0.1, 0.1, 0.1))
+		cam := cameraMatrix(win, player.pos)
+		win.SetMatrix(cam)
+		DrawWorld(win)
+		DrawArena(win)
 		movingX, movingY := 0, 0
 
-		// Handle player movement
-		if win.Pressed(pixelgl.KeyW) || win.Pressed(pixelgl.KeyUp) {
+		// Handle player movement, unless the keys are going to the chat
+		if !typing && (win.Pressed(pixelgl.KeyW) || win.Pressed(pixelgl.KeyUp)) {
 			// player.MoveUp()
 			movingY++
 		}
-		if win.Pressed(pixelgl.KeyS) || win.Pressed(pixelgl.KeyDown) {
+		if !typing && (win.Pressed(pixelgl.KeyS) || win.Pressed(pixelgl.KeyDown)) {
 			// player.MoveDown()
 			movingY--
 		}
-		if win.Pressed(pixelgl.KeyA) || win.Pressed(pixelgl.KeyLeft) {
+		if !typing && (win.Pressed(pixelgl.KeyA) || win.Pressed(pixelgl.KeyLeft)) {
 			// player.MoveLeft()
 			movingX--
 		}
-		if win.Pressed(pixelgl.KeyD) || win.Pressed(pixelgl.KeyRight) {
+		if !typing && (win.Pressed(pixelgl.KeyD) || win.Pressed(pixelgl.KeyRight)) {
 			// player.MoveRight()
 			movingX++
 		}
 
-		// Update player direction based on mouse position
-		mousePos := win.MousePosition()
-		direction := mousePos
-		player.direction = direction
+		// Update player direction based on mouse position, in world coordinates
+		mousePos := cam.Unproject(win.MousePosition())
+		player.aim = mousePos
+		if dir := mousePos.Sub(player.pos); dir.Len() > 0 {
+			player.direction = dir.Unit()
+		}
 
 		select {
 		case <-stateTicker.C:
+			input := player.predictInput(movingX, movingY)
 
 			msg := Message{
 				ClientID: playerID,
 				Type:     "player_moving",
 				Content: map[string]interface{}{
 					"id":         playerID,
-					"directionX": player.direction.X,
-					"directionY": player.direction.Y,
+					"directionX": player.aim.X,
+					"directionY": player.aim.Y,
 					// "heroClass":  heroClass,
 					// "nickname": player.nickname,
 					"movingX": movingX,
 					"movingY": movingY,
+					"seq":     input.seq,
 				},
 			}
 
 			if err := conn.WriteJSON(msg); err != nil {
 				log.Println("states write:", err)
-				return
+				return false
 
 			}
 		default:
 		}
 
+		select {
+		case <-pingTicker.C:
+			if err := sendPing(conn); err != nil {
+				log.Println("ping write:", err)
+				return false
+			}
+		default:
+		}
+
 		// Process all pending messages
 		for {
 			select {
@@ -291,23 +327,46 @@ func startGame(win *pixelgl.Window, conn *websocket.Conn) {
 				Type:     "player_attack",
 				Content: PlayerState{
 					"id":         player.ID,
-					"directionX": player.direction.X,
-					"directionY": player.direction.Y,
+					"directionX": player.aim.X,
+					"directionY": player.aim.Y,
+					"viewTick":   lastSnapshotTick,
 				},
 			}
 			if err := conn.WriteJSON(msg); err != nil {
 				log.Println("write:", err)
-				return
+				return false
 			}
 
 		}
+		if !typing {
+			if err := sendAbilityInputs(win, conn, &player); err != nil {
+				log.Println("ability write:", err)
+				return false
+			}
+		}
 
+		DrawSafeZone(win)
+		DrawZones(win)
+		DrawFlags(win)
 		// // Draw all players every frame
 		DrawOtherPlayers(win)
+		player.Draw(win)
+		DrawMonsters(win)
 		DrawProjectiles(win)
 		DrawExplosions(win)
 		DrawMeleeEffects(win)
+		DrawDamageNumbers(win)
+		win.SetMatrix(pixel.IM)
+		DrawPing(win)
+		DrawWaveHUD(win)
+		DrawTeamHUD(win)
+		DrawZoneHUD(win)
+		DrawSafeZoneHUD(win, player.pos)
+		DrawMatchHUD(win)
+		DrawAbilityHUD(win)
+		DrawChat(win)
 		win.Update()
 
 	}
+	return false
 }
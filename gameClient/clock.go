@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/pixelgl"
+	"github.com/gopxl/pixel/text"
+	"github.com/gorilla/websocket"
+	"golang.org/x/image/font/basicfont"
+)
+
+const (
+	pingInterval   = time.Second
+	clockSmoothing = 0.1 // weight of a new sample in the running averages
+)
+
+// Estimates from the ping/pong exchange. Only touched from the game loop.
+var (
+	rtt          time.Duration
+	jitter       time.Duration
+	clockOffset  time.Duration // server clock minus local clock
+	clockSamples int
+)
+
+type Pong struct {
+	ClientTime int64  `json:"clientTime"`
+	ServerTime int64  `json:"serverTime"`
+	Tick       uint64 `json:"tick"`
+}
+
+func sendPing(conn *websocket.Conn) error {
+	return conn.WriteJSON(Message{
+		ClientID: playerID,
+		Type:     "ping",
+		Content: map[string]interface{}{
+			"clientTime": time.Now().UnixMilli(),
+		},
+	})
+}
+
+func handlePong(msg Message) {
+	var pong Pong
+	data, err := json.Marshal(msg.Content)
+	if err != nil {
+		log.Printf("Error marshaling pong: %v", err)
+		return
+	}
+	if err := json.Unmarshal(data, &pong); err != nil {
+		log.Printf("Error unmarshaling pong: %v", err)
+		return
+	}
+
+	now := time.Now()
+	sample := now.Sub(time.UnixMilli(pong.ClientTime))
+	// The server read its clock roughly half way through the round trip
+	offset := time.UnixMilli(pong.ServerTime).Add(sample / 2).Sub(now)
+
+	if clockSamples == 0 {
+		rtt = sample
+		clockOffset = offset
+	} else {
+		diff := sample - rtt
+		if diff < 0 {
+			diff = -diff
+		}
+		jitter = smooth(jitter, diff)
+		rtt = smooth(rtt, sample)
+		clockOffset = smooth(clockOffset, offset)
+	}
+	clockSamples++
+}
+
+func smooth(avg, sample time.Duration) time.Duration {
+	return avg + time.Duration(clockSmoothing*float64(sample-avg))
+}
+
+// snapshotTime converts the server timestamp of a snapshot to the local
+// clock, falling back to the arrival time until the clock is synced.
+func snapshotTime(msg Message) time.Time {
+	if clockSamples == 0 || msg.Time == 0 {
+		return time.Now()
+	}
+	return time.UnixMilli(msg.Time).Add(-clockOffset)
+}
+
+var hudAtlas = text.NewAtlas(basicfont.Face7x13, text.ASCII)
+
+func DrawPing(win *pixelgl.Window) {
+	txt := text.New(pixel.V(10, win.Bounds().H()-20), hudAtlas)
+	txt.Color = pixel.RGB(1, 1, 1)
+	if clockSamples == 0 {
+		fmt.Fprint(txt, "Ping: --")
+	} else {
+		fmt.Fprintf(txt, "Ping: %d ms (+/-%d)", rtt.Milliseconds(), jitter.Milliseconds())
+	}
+	txt.Draw(win, pixel.IM)
+}
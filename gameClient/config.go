@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// envDuration reads a duration setting from the environment, falling back to
+// def when the variable is unset or malformed.
+func envDuration(name string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(name)); err == nil {
+		return v
+	}
+	return def
+}
+
+var cachedPlayerKey string
+
+// playerKey is the random key the server rates us by. It's kept in
+// PLAYER_KEY_FILE (player.key by default) so it stays the same between
+// sessions, and anyone can pick any nickname without taking our rating.
+func playerKey() string {
+	if cachedPlayerKey != "" {
+		return cachedPlayerKey
+	}
+	path := os.Getenv("PLAYER_KEY_FILE")
+	if path == "" {
+		path = "player.key"
+	}
+	if data, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(data))) >= 16 {
+		cachedPlayerKey = strings.TrimSpace(string(data))
+		return cachedPlayerKey
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Println("player key:", err)
+		return ""
+	}
+	cachedPlayerKey = hex.EncodeToString(buf)
+	if err := os.WriteFile(path, []byte(cachedPlayerKey+"\n"), 0600); err != nil {
+		log.Println("player key:", err)
+	}
+	return cachedPlayerKey
+}
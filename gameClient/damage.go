@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/pixelgl"
+	"github.com/gopxl/pixel/text"
+)
+
+type DamageEvent struct {
+	SourceID int     `json:"source"`
+	TargetID int     `json:"target"`
+	Type     string  `json:"type"`
+	Amount   float64 `json:"amount"`
+	Absorbed float64 `json:"absorbed"`
+	Crit     bool    `json:"crit"`
+	Killed   bool    `json:"killed"`
+	PosX     float64 `json:"posX"`
+	PosY     float64 `json:"posY"`
+
+	SourceMonster int `json:"sourceMonster"`
+	TargetMonster int `json:"targetMonster"`
+}
+
+// A number floating up from where a player got hit
+type damageNumber struct {
+	pos      pixel.Vec
+	amount   float64
+	absorbed float64
+	crit     bool
+	kind     string
+	born     time.Time
+}
+
+var damageNumbers = make(map[int]*damageNumber) // by target, monsters negated
+var dmu sync.Mutex
+
+const (
+	damageNumberLife = time.Second
+	// Hits on the same target this close together add up into one number,
+	// so damage over time doesn't flood the screen
+	damageNumberMerge = 300 * time.Millisecond
+)
+
+func handleDamageEvent(msg Message) {
+	var event DamageEvent
+	data, err := json.Marshal(msg.Content)
+	if err != nil {
+		log.Printf("Error marshaling damage: %v", err)
+		return
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		log.Printf("Error unmarshaling damage: %v", err)
+		return
+	}
+	target := event.TargetID
+	if event.TargetMonster != 0 {
+		target = -event.TargetMonster
+	}
+	now := time.Now()
+	dmu.Lock()
+	defer dmu.Unlock()
+	if number, ok := damageNumbers[target]; ok && now.Sub(number.born) < damageNumberMerge && number.kind == event.Type {
+		number.amount += event.Amount
+		number.absorbed += event.Absorbed
+		number.crit = number.crit || event.Crit
+		return
+	}
+	damageNumbers[target] = &damageNumber{
+		pos:      pixel.V(event.PosX, event.PosY),
+		amount:   event.Amount,
+		absorbed: event.Absorbed,
+		crit:     event.Crit,
+		kind:     event.Type,
+		born:     now,
+	}
+}
+
+// DrawDamageNumbers draws recent hits in world coordinates.
+func DrawDamageNumbers(win *pixelgl.Window) {
+	dmu.Lock()
+	defer dmu.Unlock()
+	for id, number := range damageNumbers {
+		age := time.Since(number.born)
+		if age > damageNumberLife {
+			delete(damageNumbers, id)
+			continue
+		}
+		progress := age.Seconds() / damageNumberLife.Seconds()
+		txt := text.New(number.pos.Add(pixel.V(0, 25+progress*30)), hudAtlas)
+		switch {
+		case number.amount < 0.5 && number.absorbed > 0:
+			txt.Color = pixel.RGBA{R: 0.4, G: 0.9, B: 1, A: 1 - progress}
+			fmt.Fprintf(txt, "(%.0f)", number.absorbed)
+		case number.kind == "magic":
+			txt.Color = pixel.RGBA{R: 0.6, G: 0.5, B: 1, A: 1 - progress}
+			fmt.Fprintf(txt, "%.0f", number.amount)
+		default:
+			txt.Color = pixel.RGBA{R: 1, G: 0.9, B: 0.3, A: 1 - progress}
+			fmt.Fprintf(txt, "%.0f", number.amount)
+		}
+		scale := 1.0
+		if number.crit {
+			fmt.Fprint(txt, "!")
+			scale = 1.6
+		}
+		txt.Draw(win, pixel.IM.Scaled(txt.Orig, scale))
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/imdraw"
+	"github.com/gopxl/pixel/pixelgl"
+	"github.com/gopxl/pixel/text"
+)
+
+const (
+	editorTileSize   = 40.0
+	editorCameraStep = 10.0
+	editorPickRadius = 15.0 // how close a right click has to be to remove an object
+)
+
+type editorTool struct {
+	name   string
+	tile   int          // tile painted by tile tools
+	points *[]pixel.Vec // list edited by object tools, nil for tile tools
+	button *Button
+}
+
+// MapEditor edits a TileMap in the same format the server loads.
+type MapEditor struct {
+	m      *TileMap
+	undo   []*TileMap
+	redo   []*TileMap
+	tool   *editorTool
+	camera pixel.Vec
+	status string
+	imd    *imdraw.IMDraw
+}
+
+func editorMapPath() string {
+	if path := os.Getenv("MAP_FILE"); path != "" {
+		return path
+	}
+	return "map.json"
+}
+
+func newEditorMap() *TileMap {
+	m := &TileMap{
+		Width:    int(worldBounds.W() / editorTileSize),
+		Height:   int(worldBounds.H() / editorTileSize),
+		TileSize: editorTileSize,
+	}
+	m.Tiles = make([]int, m.Width*m.Height)
+	return m
+}
+
+func (m *TileMap) clone() *TileMap {
+	c := *m
+	c.Tiles = append([]int(nil), m.Tiles...)
+	c.Spawns = append([]pixel.Vec(nil), m.Spawns...)
+	c.Pickups = append([]pixel.Vec(nil), m.Pickups...)
+	c.Objectives = append([]pixel.Vec(nil), m.Objectives...)
+	return &c
+}
+
+// checkpoint saves a copy of the map from before an edit so the edit can be
+// undone. Only call it once the edit actually changed something.
+func (e *MapEditor) checkpoint(before *TileMap) {
+	e.undo = append(e.undo, before)
+	e.redo = nil
+}
+
+func (e *MapEditor) Undo() {
+	if len(e.undo) == 0 {
+		return
+	}
+	e.redo = append(e.redo, e.m)
+	e.m = e.undo[len(e.undo)-1]
+	e.undo = e.undo[:len(e.undo)-1]
+}
+
+func (e *MapEditor) Redo() {
+	if len(e.redo) == 0 {
+		return
+	}
+	e.undo = append(e.undo, e.m)
+	e.m = e.redo[len(e.redo)-1]
+	e.redo = e.redo[:len(e.redo)-1]
+}
+
+func (e *MapEditor) Save() {
+	data, err := json.MarshalIndent(e.m, "", "  ")
+	if err == nil {
+		err = os.WriteFile(editorMapPath(), data, 0644)
+	}
+	if err != nil {
+		e.status = fmt.Sprintf("Save failed: %v", err)
+		log.Println("map save:", err)
+		return
+	}
+	e.status = "Saved " + editorMapPath()
+}
+
+func (e *MapEditor) Load() {
+	var m TileMap
+	data, err := os.ReadFile(editorMapPath())
+	if err == nil {
+		err = json.Unmarshal(data, &m)
+	}
+	if err == nil && (m.Width <= 0 || m.Height <= 0 || m.TileSize <= 0 || len(m.Tiles) != m.Width*m.Height) {
+		err = fmt.Errorf("invalid map size")
+	}
+	if err != nil {
+		e.status = fmt.Sprintf("Load failed: %v", err)
+		log.Println("map load:", err)
+		return
+	}
+	e.checkpoint(e.m)
+	e.m = &m
+	e.status = "Loaded " + editorMapPath()
+}
+
+// paint sets the tile under pos, returning false if nothing changed.
+func (e *MapEditor) paint(pos pixel.Vec, tile int) bool {
+	tx, ty := int(pos.X/e.m.TileSize), int(pos.Y/e.m.TileSize)
+	if pos.X < 0 || pos.Y < 0 || tx >= e.m.Width || ty >= e.m.Height {
+		return false
+	}
+	if e.m.Tiles[ty*e.m.Width+tx] == tile {
+		return false
+	}
+	e.m.Tiles[ty*e.m.Width+tx] = tile
+	return true
+}
+
+// removeObject deletes the first object of any kind near pos.
+func (e *MapEditor) removeObject(pos pixel.Vec) bool {
+	for _, points := range []*[]pixel.Vec{&e.m.Spawns, &e.m.Pickups, &e.m.Objectives} {
+		for i, p := range *points {
+			if p.Sub(pos).Len() <= editorPickRadius {
+				*points = append((*points)[:i], (*points)[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var objectColors = []pixel.RGBA{
+	pixel.RGB(1, 1, 1),     // spawns
+	pixel.RGB(1, 0.8, 0),   // pickups
+	pixel.RGB(0.8, 0, 0.8), // objectives
+}
+
+func (e *MapEditor) Draw(win *pixelgl.Window) {
+	e.imd.Clear()
+	e.imd.Color = pixel.RGB(0, 0.5, 0.4)
+	e.imd.Push(pixel.ZV, pixel.V(float64(e.m.Width)*e.m.TileSize, float64(e.m.Height)*e.m.TileSize))
+	e.imd.Rectangle(0)
+	drawTiles(e.imd, e.m)
+	for i, points := range [][]pixel.Vec{e.m.Spawns, e.m.Pickups, e.m.Objectives} {
+		e.imd.Color = objectColors[i]
+		for _, p := range points {
+			e.imd.Push(p)
+			e.imd.Circle(editorPickRadius, 3)
+		}
+	}
+	e.imd.Draw(win)
+}
+
+// runEditor runs the map editor until the window is closed or Exit is
+// clicked. Tiles are painted with the left mouse button, right click removes
+// objects, WASD moves the camera.
+func runEditor(win *pixelgl.Window) {
+	editor := &MapEditor{
+		m:      newEditorMap(),
+		camera: worldBounds.Center(),
+		imd:    imdraw.New(nil),
+	}
+
+	tools := []*editorTool{
+		{name: "Floor", tile: TileWalkable},
+		{name: "Wall", tile: TileSolid},
+		{name: "Decor", tile: TileDecorative},
+		{name: "Spawn", points: &editor.m.Spawns},
+		{name: "Pickup", points: &editor.m.Pickups},
+		{name: "Objective", points: &editor.m.Objectives},
+	}
+	x := 10.0
+	top := win.Bounds().H() - 25
+	for _, tool := range tools {
+		tool.button = NewButton(pixel.V(x, top), tool.name, hudAtlas, 1, 1, 1)
+		x += tool.button.rect.W() + 15
+	}
+	editor.tool = tools[1]
+
+	undoButton := NewButton(pixel.V(x, top), "Undo", hudAtlas, 0.7, 0.7, 0.7)
+	redoButton := NewButton(pixel.V(x+50, top), "Redo", hudAtlas, 0.7, 0.7, 0.7)
+	saveButton := NewButton(pixel.V(x+100, top), "Save", hudAtlas, 0, 1, 0)
+	loadButton := NewButton(pixel.V(x+150, top), "Load", hudAtlas, 0, 1, 0)
+	exitButton := NewButton(pixel.V(x+200, top), "Exit", hudAtlas, 1, 0, 0)
+	statusText := text.New(pixel.V(10, 10), hudAtlas)
+	var stroke *TileMap // the map before the current paint stroke, nil once saved
+
+	for !win.Closed() {
+		// The server keeps sending chat and lobby updates while we're here
+		for pending := true; pending; {
+			select {
+			case msg := <-receive:
+				HandleMessage(msg, nil)
+			default:
+				pending = false
+			}
+		}
+
+		win.SetMatrix(pixel.IM)
+		ctrl := win.Pressed(pixelgl.KeyLeftControl) || win.Pressed(pixelgl.KeyRightControl)
+
+		// Toolbar, in screen coordinates
+		overToolbar := win.MousePosition().Y > top-5
+		for _, tool := range tools {
+			if tool.button.IsClicked(win) {
+				editor.tool = tool
+			}
+		}
+		if undoButton.IsClicked(win) || ctrl && win.JustPressed(pixelgl.KeyZ) {
+			editor.Undo()
+		}
+		if redoButton.IsClicked(win) || ctrl && win.JustPressed(pixelgl.KeyY) {
+			editor.Redo()
+		}
+		if saveButton.IsClicked(win) || ctrl && win.JustPressed(pixelgl.KeyS) {
+			editor.Save()
+		}
+		if loadButton.IsClicked(win) {
+			editor.Load()
+		}
+		if exitButton.IsClicked(win) {
+			return
+		}
+		// Undo, redo and load swap the map, keep the object tools pointing at it
+		tools[3].points, tools[4].points, tools[5].points = &editor.m.Spawns, &editor.m.Pickups, &editor.m.Objectives
+
+		if !ctrl {
+			if win.Pressed(pixelgl.KeyW) || win.Pressed(pixelgl.KeyUp) {
+				editor.camera.Y += editorCameraStep
+			}
+			if win.Pressed(pixelgl.KeyS) || win.Pressed(pixelgl.KeyDown) {
+				editor.camera.Y -= editorCameraStep
+			}
+			if win.Pressed(pixelgl.KeyA) || win.Pressed(pixelgl.KeyLeft) {
+				editor.camera.X -= editorCameraStep
+			}
+			if win.Pressed(pixelgl.KeyD) || win.Pressed(pixelgl.KeyRight) {
+				editor.camera.X += editorCameraStep
+			}
+		}
+
+		// Map edits, in world coordinates
+		cam := cameraMatrix(win, editor.camera)
+		mouse := cam.Unproject(win.MousePosition())
+		if !win.Pressed(pixelgl.MouseButtonLeft) {
+			stroke = nil
+		}
+		if !overToolbar {
+			if editor.tool.points == nil {
+				// One undo step per stroke, if it painted anything
+				if win.JustPressed(pixelgl.MouseButtonLeft) {
+					stroke = editor.m.clone()
+				}
+				if win.Pressed(pixelgl.MouseButtonLeft) && editor.paint(mouse, editor.tool.tile) && stroke != nil {
+					editor.checkpoint(stroke)
+					stroke = nil
+				}
+			} else if win.JustPressed(pixelgl.MouseButtonLeft) {
+				editor.checkpoint(editor.m.clone())
+				*editor.tool.points = append(*editor.tool.points, mouse)
+			}
+			if win.JustPressed(pixelgl.MouseButtonRight) {
+				before := editor.m.clone()
+				if editor.removeObject(mouse) {
+					editor.checkpoint(before)
+				}
+			}
+		}
+
+		win.Clear(pixel.RGB(0.1, 0.1, 0.1))
+		win.SetMatrix(cam)
+		editor.Draw(win)
+
+		win.SetMatrix(pixel.IM)
+		for _, tool := range tools {
+			if tool == editor.tool {
+				tool.button.color = pixel.RGB(1, 1, 0)
+			} else {
+				tool.button.color = pixel.RGB(1, 1, 1)
+			}
+			tool.button.Draw(win)
+		}
+		undoButton.Draw(win)
+		redoButton.Draw(win)
+		saveButton.Draw(win)
+		loadButton.Draw(win)
+		exitButton.Draw(win)
+		statusText.Clear()
+		fmt.Fprintf(statusText, "Tool: %s  Map: %dx%d  %s", editor.tool.name, editor.m.Width, editor.m.Height, editor.status)
+		statusText.Draw(win, pixel.IM)
+
+		win.Update()
+	}
+}
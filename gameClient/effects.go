@@ -1,6 +1,9 @@
 package main
 
 import (
+	"math"
+	"time"
+
 	"github.com/gopxl/pixel"
 	"github.com/gopxl/pixel/imdraw"
 )
@@ -12,9 +15,13 @@ type MeleeEffect struct {
 	imd       *imdraw.IMDraw
 	lifetime  float64
 	maxLife   float64
-	direction pixel.Vec
+	direction float64 // angle of the swing's center line
+	width     float64 // radians swept, 2π for all around
 }
 
+// How long the blade takes to cross the arc, the rest of the effect fades
+const meleeSweepTime = 0.15
+
 func (m *MeleeEffect) Update() bool {
 	m.lifetime += dt
 	// m.pos = pos
@@ -30,29 +37,44 @@ func (m *MeleeEffect) Draw(win pixel.Target) {
 		alpha := 1.0 - (m.lifetime / m.maxLife) // Fade out effect
 		m.imd.Color = pixel.RGBA{R: 1, G: 0.2, B: 0.2, A: alpha}
 		m.imd.Push(m.pos)
-		m.imd.Circle(m.radius, 0)
+		if m.width >= 2*math.Pi {
+			m.imd.Circle(m.radius, 0)
+		} else {
+			// Sweep from one edge of the arc to the other
+			swept := m.width * math.Min(1, m.lifetime/meleeSweepTime)
+			start := m.direction - m.width/2
+			m.imd.CircleArc(m.radius, start, start+swept, 0)
+		}
 		m.imd.Draw(win)
 	}
 }
 
 // Add this function near other constructor functions
-func NewMeleeEffect(circle CircleState) *MeleeEffect {
+func NewMeleeEffect(swing MeleeState) *MeleeEffect {
 	var pos pixel.Vec
-	pos.X = circle.X
-	pos.Y = circle.Y
+	pos.X = swing.X
+	pos.Y = swing.Y
+	width := swing.Width
+	if width <= 0 {
+		width = 2 * math.Pi // monster swings are plain circles
+	}
 	return &MeleeEffect{
-		pos:      pos,
-		radius:   circle.Radius,
-		imd:      imdraw.New(nil),
-		lifetime: 0,
-		maxLife:  0.5, // half second duration
+		pos:       pos,
+		radius:    swing.Radius,
+		imd:       imdraw.New(nil),
+		lifetime:  0,
+		maxLife:   0.5, // half second duration
+		direction: swing.Direction,
+		width:     width,
 	}
 }
 
 // Add this to your existing Projectile struct
 type Projectile struct {
-	pos pixel.Vec
-	imd *imdraw.IMDraw
+	pos       pixel.Vec
+	imd       *imdraw.IMDraw
+	snapshots snapshotBuffer
+	removedAt time.Time // when the server stopped sending it
 }
 
 func (p *Projectile) Draw(win pixel.Target) {
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/imdraw"
+	"github.com/gopxl/pixel/pixelgl"
+	"github.com/gopxl/pixel/text"
+)
+
+type FlagState struct {
+	Team    int       `json:"team"`
+	Base    pixel.Vec `json:"base"`
+	Pos     pixel.Vec `json:"pos"`
+	Carrier int       `json:"carrier"`
+	AtBase  bool      `json:"atBase"`
+	Hidden  bool      `json:"hidden"` // out of view, only the base is known
+}
+
+var flags []FlagState
+var flagmu sync.Mutex
+var flagImd = imdraw.New(nil)
+
+func handleFlagsUpdate(msg Message) {
+	var states []FlagState
+	data, err := json.Marshal(msg.Content)
+	if err != nil {
+		log.Printf("Error marshaling flags: %v", err)
+		return
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		log.Printf("Error unmarshaling flags: %v", err)
+		return
+	}
+	flagmu.Lock()
+	flags = states
+	flagmu.Unlock()
+}
+
+func handleFlagEvent(msg Message) {
+	content, _ := msg.Content.(map[string]interface{})
+	team, _ := content["team"].(float64)
+	id, _ := content["player"].(float64)
+	who := playerName(int(id))
+	switch msg.Type {
+	case "flag_taken":
+		showTeamBanner(fmt.Sprintf("%s took the team %d flag", who, int(team)))
+	case "flag_captured":
+		showTeamBanner(fmt.Sprintf("%s captured the team %d flag", who, int(team)))
+	case "flag_dropped":
+		showTeamBanner(fmt.Sprintf("%s dropped the team %d flag", who, int(team)))
+	case "flag_returned":
+		showTeamBanner(fmt.Sprintf("Team %d flag returned", int(team)))
+	}
+}
+
+// playerName is the nickname we know for a player, if they're in view.
+func playerName(id int) string {
+	if id == playerID {
+		return "You"
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if other, ok := otherPlayers[id]; ok && other.Player.nickname != "" {
+		return other.Player.nickname
+	}
+	return fmt.Sprintf("Player %d", id)
+}
+
+// DrawFlags draws each team's base and flag, labelled with its carrier.
+func DrawFlags(win *pixelgl.Window) {
+	flagmu.Lock()
+	states := append([]FlagState(nil), flags...)
+	flagmu.Unlock()
+
+	flagImd.Clear()
+	for _, flag := range states {
+		color := teamColors[flag.Team]
+		flagImd.Color = color
+		flagImd.Push(flag.Base)
+		flagImd.Circle(30, 2)
+		if flag.Hidden {
+			continue
+		}
+
+		// Pole and pennant
+		flagImd.Color = pixel.RGB(0.3, 0.3, 0.3)
+		flagImd.Push(flag.Pos, flag.Pos.Add(pixel.V(0, 30)))
+		flagImd.Line(2)
+		flagImd.Color = color
+		flagImd.Push(flag.Pos.Add(pixel.V(0, 30)), flag.Pos.Add(pixel.V(18, 24)), flag.Pos.Add(pixel.V(0, 18)))
+		flagImd.Polygon(0)
+	}
+	flagImd.Draw(win)
+
+	for _, flag := range states {
+		if flag.Carrier == 0 || flag.Hidden {
+			continue
+		}
+		label := text.New(flag.Pos.Add(pixel.V(-20, 36)), hudAtlas)
+		label.Color = teamColors[flag.Team]
+		fmt.Fprint(label, playerName(flag.Carrier))
+		label.Draw(win, pixel.IM)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gopxl/pixel"
+)
+
+// Remote entities are drawn interpolationDelay in the past so there is
+// usually a snapshot on both sides of the render time. When packets are late
+// we extrapolate for at most maxExtrapolation before freezing.
+var (
+	interpolationDelay = envDuration("INTERP_DELAY", 100*time.Millisecond)
+	maxExtrapolation   = envDuration("MAX_EXTRAPOLATION", 250*time.Millisecond)
+)
+
+const snapshotBufferSize = 32
+
+type snapshot struct {
+	t   time.Time
+	pos pixel.Vec
+}
+
+type snapshotBuffer struct {
+	snaps []snapshot
+}
+
+func (b *snapshotBuffer) Push(t time.Time, pos pixel.Vec) {
+	// Drop out of order snapshots
+	if n := len(b.snaps); n > 0 && !t.After(b.snaps[n-1].t) {
+		return
+	}
+	b.snaps = append(b.snaps, snapshot{t: t, pos: pos})
+	if len(b.snaps) > snapshotBufferSize {
+		b.snaps = b.snaps[len(b.snaps)-snapshotBufferSize:]
+	}
+}
+
+// Sample returns the interpolated position at renderTime.
+func (b *snapshotBuffer) Sample(renderTime time.Time) (pixel.Vec, bool) {
+	n := len(b.snaps)
+	if n == 0 {
+		return pixel.ZV, false
+	}
+	if !renderTime.After(b.snaps[0].t) {
+		return b.snaps[0].pos, true
+	}
+
+	for i := n - 1; i > 0; i-- {
+		from, to := b.snaps[i-1], b.snaps[i]
+		if !renderTime.Before(from.t) && renderTime.Before(to.t) {
+			t := renderTime.Sub(from.t).Seconds() / to.t.Sub(from.t).Seconds()
+			return pixel.Lerp(from.pos, to.pos, t), true
+		}
+	}
+
+	// Render time is past the newest snapshot, keep moving with the last
+	// known velocity for a short while
+	last := b.snaps[n-1]
+	if n == 1 {
+		return last.pos, true
+	}
+	prev := b.snaps[n-2]
+	ahead := renderTime.Sub(last.t)
+	if ahead > maxExtrapolation {
+		ahead = maxExtrapolation
+	}
+	velocity := last.pos.Sub(prev.pos).Scaled(1 / last.t.Sub(prev.t).Seconds())
+	return last.pos.Add(velocity.Scaled(ahead.Seconds())), true
+}
+
+func renderTime() time.Time {
+	return time.Now().Add(-interpolationDelay)
+}
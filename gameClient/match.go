@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/pixelgl"
+	"github.com/gopxl/pixel/text"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	MatchLobby      = "lobby"
+	MatchReadyCheck = "ready_check"
+	MatchCountdown  = "countdown"
+	MatchPlaying    = "playing"
+	MatchResults    = "results"
+)
+
+var classNames = map[int]string{1: "Warrior", 2: "Mage"}
+
+type MatchPlayer struct {
+	ID        int     `json:"id"`
+	Nickname  string  `json:"nickname"`
+	HeroClass int     `json:"heroClass"`
+	Ready     bool    `json:"ready"`
+	Rating    float64 `json:"rating"`
+}
+
+type PlayerStats struct {
+	ID       int     `json:"id"`
+	Nickname string  `json:"nickname"`
+	Kills    int     `json:"kills"`
+	Deaths   int     `json:"deaths"`
+	Damage   float64 `json:"damage"`
+}
+
+type MatchInfo struct {
+	State      string        `json:"state"`
+	Mode       string        `json:"mode"`
+	Remaining  float64       `json:"remaining"`
+	MinPlayers int           `json:"minPlayers"`
+	Players    []MatchPlayer `json:"players"`
+	CanJoin    bool          `json:"canJoin"`
+	Summary    string        `json:"summary"`
+	Results    []PlayerStats `json:"results"`
+}
+
+type QueueStatus struct {
+	Queued    bool    `json:"queued"`
+	Position  int     `json:"position"`
+	QueueSize int     `json:"queueSize"`
+	Waited    float64 `json:"waited"`
+	Window    float64 `json:"window"`
+	Rating    float64 `json:"rating"`
+	Games     int     `json:"games"`
+}
+
+var (
+	match         = MatchInfo{State: MatchLobby}
+	matchReceived time.Time
+	queueStatus   QueueStatus
+	matchmu       sync.Mutex
+)
+
+// How often the match screens ask the server for our place in the queue
+const queueStatusInterval = time.Second
+
+func handleMatchState(msg Message) {
+	var info MatchInfo
+	data, err := json.Marshal(msg.Content)
+	if err != nil {
+		log.Printf("Error marshaling match state: %v", err)
+		return
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		log.Printf("Error unmarshaling match state: %v", err)
+		return
+	}
+	matchmu.Lock()
+	previous := match.State
+	match = info
+	matchReceived = time.Now()
+	matchmu.Unlock()
+
+	if info.State == MatchLobby && previous != MatchLobby {
+		// The server cleared the world for the next match
+		playerExists = false
+		mu.Lock()
+		for id := range otherPlayers {
+			delete(otherPlayers, id)
+		}
+		mu.Unlock()
+	}
+}
+
+func handleQueueStatus(msg Message) {
+	var status QueueStatus
+	data, err := json.Marshal(msg.Content)
+	if err != nil {
+		log.Printf("Error marshaling queue status: %v", err)
+		return
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		log.Printf("Error unmarshaling queue status: %v", err)
+		return
+	}
+	matchmu.Lock()
+	queueStatus = status
+	matchmu.Unlock()
+}
+
+func currentMatch() (MatchInfo, float64) {
+	matchmu.Lock()
+	defer matchmu.Unlock()
+	remaining := math.Max(0, match.Remaining-time.Since(matchReceived).Seconds())
+	return match, remaining
+}
+
+// runMatchScreens shows the lobby, ready check, countdown and results until
+// our player is in a running match. Returns false if the player left for the
+// main menu.
+func runMatchScreens(win *pixelgl.Window, conn *websocket.Conn, join Message) bool {
+	win.SetMatrix(pixel.IM)
+	queueTicker := time.NewTicker(queueStatusInterval)
+	defer queueTicker.Stop()
+	for !win.Closed() {
+		time.Sleep(time.Second / fps)
+		select {
+		case <-queueTicker.C:
+			if err := conn.WriteJSON(Message{ClientID: playerID, Type: "queue_status"}); err != nil {
+				log.Println("queue status write:", err)
+				return false
+			}
+		default:
+		}
+		for pending := true; pending; {
+			select {
+			case msg := <-receive:
+				HandleMessage(msg, nil)
+			default:
+				pending = false
+			}
+		}
+
+		info, remaining := currentMatch()
+		if info.State == MatchPlaying && playerExists {
+			return true
+		}
+		typing, err := updateChatInput(win, conn)
+		if err != nil {
+			log.Println("chat write:", err)
+			return false
+		}
+		if !typing && win.JustPressed(pixelgl.KeyEscape) {
+			return false
+		}
+		if !typing && win.JustPressed(pixelgl.KeyR) {
+			var err error
+			switch info.State {
+			case MatchLobby, MatchReadyCheck, MatchCountdown:
+				err = conn.WriteJSON(Message{
+					ClientID: playerID,
+					Type:     "player_ready",
+					Content:  map[string]interface{}{"ready": !isReady(info)},
+				})
+			case MatchPlaying:
+				if info.CanJoin {
+					err = conn.WriteJSON(join)
+				}
+			}
+			if err != nil {
+				log.Println("match write:", err)
+				return false
+			}
+		}
+
+		win.Clear(pixel.RGB(0.1, 0.1, 0.1))
+		drawMatchScreen(win, info, remaining)
+		DrawChat(win)
+		win.Update()
+	}
+	return false
+}
+
+func isReady(info MatchInfo) bool {
+	for _, p := range info.Players {
+		if p.ID == playerID {
+			return p.Ready
+		}
+	}
+	return false
+}
+
+func drawMatchScreen(win *pixelgl.Window, info MatchInfo, remaining float64) {
+	title := text.New(pixel.ZV, hudAtlas)
+	title.Color = pixel.RGB(1, 1, 1)
+	body := text.New(pixel.V(win.Bounds().W()/2-200, win.Bounds().H()-200), hudAtlas)
+	body.LineHeight = hudAtlas.LineHeight() * 1.5
+
+	switch info.State {
+	case MatchLobby:
+		fmt.Fprintf(title, "Lobby - %s", strings.ToUpper(info.Mode))
+		fmt.Fprintf(body, "Waiting for players (%d/%d)\n\n", len(info.Players), info.MinPlayers)
+		drawLobbyList(body, info)
+		drawQueueStatus(body)
+		fmt.Fprintln(body, "\nPress R to ready up, Esc to leave")
+	case MatchReadyCheck:
+		fmt.Fprintf(title, "Ready check - %.0fs", math.Ceil(remaining))
+		drawLobbyList(body, info)
+		fmt.Fprintln(body, "\nPress R to ready up")
+	case MatchCountdown:
+		if remaining > 0 {
+			fmt.Fprintf(title, "Match starts in %.0f", math.Ceil(remaining))
+		} else {
+			fmt.Fprint(title, "Finding opponents")
+		}
+		drawLobbyList(body, info)
+		drawQueueStatus(body)
+	case MatchPlaying:
+		fmt.Fprint(title, "Match in progress")
+		if info.CanJoin {
+			// Players who aren't in the match yet wait in the queue
+			fmt.Fprintln(body, "Press R to join")
+			drawQueueStatus(body)
+		} else {
+			fmt.Fprintln(body, "Wait for the next match")
+		}
+	case MatchResults:
+		fmt.Fprint(title, "Match over")
+		if info.Summary != "" {
+			fmt.Fprintf(body, "%s\n\n", info.Summary)
+		}
+		fmt.Fprintf(body, "%-16s %6s %6s %8s\n", "Player", "Kills", "Deaths", "Damage")
+		for _, stats := range info.Results {
+			fmt.Fprintf(body, "%-16s %6d %6d %8.0f\n", stats.Nickname, stats.Kills, stats.Deaths, stats.Damage)
+		}
+		fmt.Fprintf(body, "\nBack to the lobby in %.0fs", math.Ceil(remaining))
+	}
+
+	pos := pixel.V(win.Bounds().W()/2-title.Bounds().W()*1.5, win.Bounds().H()-120)
+	title.Draw(win, pixel.IM.Scaled(pixel.ZV, 3).Moved(pos))
+	body.Draw(win, pixel.IM)
+}
+
+func drawLobbyList(txt *text.Text, info MatchInfo) {
+	for _, p := range info.Players {
+		mark := "[ ]"
+		if p.Ready {
+			mark = "[x]"
+		}
+		you := ""
+		if p.ID == playerID {
+			you = " (you)"
+		}
+		fmt.Fprintf(txt, "%s %s - %s (%.0f)%s\n", mark, p.Nickname, classNames[p.HeroClass], p.Rating, you)
+	}
+}
+
+func drawQueueStatus(txt *text.Text) {
+	matchmu.Lock()
+	status := queueStatus
+	matchmu.Unlock()
+	fmt.Fprintf(txt, "\nYour rating: %.0f after %d games\n", status.Rating, status.Games)
+	if status.Queued {
+		fmt.Fprintf(txt, "Queued %d of %d for %.0fs, matching within %.0f rating\n",
+			status.Position, status.QueueSize, status.Waited, status.Window)
+	}
+}
+
+// DrawMatchHUD shows the time left in the match.
+func DrawMatchHUD(win *pixelgl.Window) {
+	info, remaining := currentMatch()
+	if info.State != MatchPlaying || remaining <= 0 {
+		return
+	}
+	txt := text.New(pixel.V(10, win.Bounds().H()-40), hudAtlas)
+	txt.Color = pixel.RGB(1, 1, 1)
+	fmt.Fprintf(txt, "%d:%02d", int(remaining)/60, int(remaining)%60)
+	txt.Draw(win, pixel.IM)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/imdraw"
+	"github.com/gopxl/pixel/pixelgl"
+	"github.com/gopxl/pixel/text"
+)
+
+type MonsterState struct {
+	ID        int     `json:"id"`
+	Class     int     `json:"class"`
+	PosX      float64 `json:"posX"`
+	PosY      float64 `json:"posY"`
+	Radius    float64 `json:"radius"`
+	Health    float64 `json:"health"`
+	MaxHealth float64 `json:"maxHealth"`
+}
+
+type Monster struct {
+	state     MonsterState
+	pos       pixel.Vec
+	snapshots snapshotBuffer
+	imd       *imdraw.IMDraw
+}
+
+var monsterColors = map[int]pixel.RGBA{
+	1: pixel.RGB(0.4, 0.8, 0.2), // zombie
+	2: pixel.RGB(0.9, 0.8, 0.1), // spitter
+	3: pixel.RGB(0.5, 0.1, 0.6), // brute
+}
+
+var monsters = make(map[int]*Monster)
+var monmu sync.Mutex
+
+// Wave survival status shown in the HUD
+var (
+	currentWave   int
+	waveBanner    string
+	waveBannerEnd time.Time
+)
+
+func handleMonstersUpdate(msg Message) {
+	var states map[int]MonsterState
+	data, err := json.Marshal(msg.Content)
+	if err != nil {
+		log.Printf("Error marshaling monsters: %v", err)
+		return
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		log.Printf("Error unmarshaling monsters: %v", err)
+		return
+	}
+
+	received := snapshotTime(msg)
+	monmu.Lock()
+	defer monmu.Unlock()
+	for id, state := range states {
+		m, exists := monsters[id]
+		if !exists {
+			m = &Monster{imd: imdraw.New(nil), pos: pixel.V(state.PosX, state.PosY)}
+			monsters[id] = m
+		}
+		m.state = state
+		m.snapshots.Push(received, pixel.V(state.PosX, state.PosY))
+	}
+	// Anything missing left our area of interest or died
+	for id := range monsters {
+		if _, exists := states[id]; !exists {
+			delete(monsters, id)
+		}
+	}
+}
+
+func handleWaveMessage(msg Message) {
+	content, _ := msg.Content.(map[string]interface{})
+	wave, _ := content["wave"].(float64)
+	switch msg.Type {
+	case "wave_started":
+		currentWave = int(wave)
+		showWaveBanner(fmt.Sprintf("Wave %d", currentWave))
+	case "wave_cleared":
+		nextIn, _ := content["nextIn"].(float64)
+		showWaveBanner(fmt.Sprintf("Wave %d cleared! Next wave in %.0fs", int(wave), nextIn))
+	case "match_over":
+		showWaveBanner(fmt.Sprintf("Game over, you reached wave %d", int(wave)))
+		currentWave = 0
+	}
+}
+
+func showWaveBanner(banner string) {
+	waveBanner = banner
+	waveBannerEnd = time.Now().Add(3 * time.Second)
+}
+
+func (m *Monster) Draw(win pixel.Target) {
+	r := m.state.Radius
+	m.imd.Clear()
+	m.imd.Color = monsterColors[m.state.Class]
+	m.imd.Push(m.pos.Sub(pixel.V(r, r)), m.pos.Add(pixel.V(r, r)))
+	m.imd.Rectangle(0)
+
+	// Health bar
+	if m.state.MaxHealth > 0 {
+		barStart := m.pos.Add(pixel.V(-r, r+4))
+		m.imd.Color = pixel.RGB(0.3, 0, 0)
+		m.imd.Push(barStart, barStart.Add(pixel.V(2*r, 3)))
+		m.imd.Rectangle(0)
+		m.imd.Color = pixel.RGB(1, 0, 0)
+		m.imd.Push(barStart, barStart.Add(pixel.V(2*r*m.state.Health/m.state.MaxHealth, 3)))
+		m.imd.Rectangle(0)
+	}
+	m.imd.Draw(win)
+}
+
+func DrawMonsters(win *pixelgl.Window) {
+	at := renderTime()
+	monmu.Lock()
+	for _, m := range monsters {
+		if pos, ok := m.snapshots.Sample(at); ok {
+			m.pos = pos
+		}
+		m.Draw(win)
+	}
+	monmu.Unlock()
+}
+
+// DrawWaveHUD shows the wave number and the latest wave announcement.
+func DrawWaveHUD(win *pixelgl.Window) {
+	if currentWave > 0 {
+		txt := text.New(pixel.V(win.Bounds().W()/2-30, win.Bounds().H()-20), hudAtlas)
+		txt.Color = pixel.RGB(1, 1, 1)
+		fmt.Fprintf(txt, "Wave %d", currentWave)
+		txt.Draw(win, pixel.IM)
+	}
+	if time.Now().Before(waveBannerEnd) {
+		txt := text.New(pixel.ZV, hudAtlas)
+		txt.Color = pixel.RGB(1, 1, 0)
+		fmt.Fprint(txt, waveBanner)
+		pos := win.Bounds().Center().Add(pixel.V(-txt.Bounds().W(), 150))
+		txt.Draw(win, pixel.IM.Scaled(pixel.ZV, 2).Moved(pos))
+	}
+}
@@ -26,8 +26,11 @@ type Player struct {
 	nickname   string
 	heroClass  int
 	direction  pixel.Vec
+	aim        pixel.Vec // mouse position the player is aiming at
 	lastAttack float64
 	health     int
+	team       int // 0 outside of team modes
+	statuses   []StatusEffect
 }
 
 // Add custom JSON marshaling methods
@@ -136,6 +139,14 @@ func (p *Player) Draw(win *pixelgl.Window) {
 	p.imd.Push(p.pos, stickEnd)
 	p.imd.Line(2)
 
+	// Team ring around the player
+	if color, ok := teamColors[p.team]; ok {
+		p.imd.Color = color
+		p.imd.Push(p.pos)
+		p.imd.Circle(p.radius+4, 2)
+	}
+	p.drawStatuses(p.imd)
+
 	// Draw everything at once
 	p.imd.Draw(win)
 
@@ -205,6 +216,12 @@ func (p *Player) Attack(conn *websocket.Conn) {
 
 }
 
+// Menu choices returned by createPlayerForm in place of a hero class
+const (
+	choiceEditor   = -1
+	choiceSpectate = -2
+)
+
 func createPlayerForm(win *pixelgl.Window) (string, int) {
 	// Replace basicfont with custom sized font
 	face, err := opentype.Parse(goregular.TTF)
@@ -227,6 +244,8 @@ func createPlayerForm(win *pixelgl.Window) (string, int) {
 	classText := text.New(pixel.V(410, 380), atlas)
 	buttonWarrior := NewButton(pixel.V(400, 350), "Warrior", atlas, 1, 0, 0)
 	buttonMage := NewButton(pixel.V(500, 350), "Mage", atlas, 0, 0, 1)
+	buttonEditor := NewButton(pixel.V(400, 250), "Map editor", atlas, 0.8, 0.8, 0.8)
+	buttonSpectate := NewButton(pixel.V(400, 200), "Spectate", atlas, 0.8, 0.8, 0.8)
 
 	heroClass := 0
 	selectedField := "nickname"
@@ -234,6 +253,17 @@ func createPlayerForm(win *pixelgl.Window) (string, int) {
 	for !win.Closed() {
 		win.Clear(pixel.RGB(0.2, 0.2, 0.2))
 
+		// Keep up with the server while the form is open, it still sends us
+		// the map and chat
+		for pending := true; pending; {
+			select {
+			case msg := <-receive:
+				HandleMessage(msg, nil)
+			default:
+				pending = false
+			}
+		}
+
 		nicknameText.Clear()
 		classText.Clear()
 
@@ -244,6 +274,8 @@ func createPlayerForm(win *pixelgl.Window) (string, int) {
 		classText.Draw(win, pixel.IM)
 		buttonWarrior.Draw(win)
 		buttonMage.Draw(win)
+		buttonEditor.Draw(win)
+		buttonSpectate.Draw(win)
 
 		if win.JustPressed(pixelgl.KeyTab) {
 			if selectedField == "nickname" {
@@ -261,6 +293,12 @@ func createPlayerForm(win *pixelgl.Window) (string, int) {
 			heroClass = 2
 			return nickname, heroClass
 		}
+		if buttonEditor.IsClicked(win) {
+			return nickname, choiceEditor
+		}
+		if buttonSpectate.IsClicked(win) {
+			return nickname, choiceSpectate
+		}
 
 		if win.JustPressed(pixelgl.KeyBackspace) {
 			if selectedField == "nickname" && len(nickname) > 0 {
@@ -0,0 +1,58 @@
+package main
+
+import "github.com/gopxl/pixel"
+
+// Movement input sent to the server but not yet acknowledged in a snapshot.
+type pendingInput struct {
+	seq     uint32
+	movingX int
+	movingY int
+}
+
+var inputSeq uint32
+var pendingInputs []pendingInput
+
+// Our class's PlayerClass.Speed, sent by the server in new_player.
+var moveSpeed float64
+
+// applyInput moves the player exactly like the server does for a single
+// player_moving message.
+func (p *Player) applyInput(in pendingInput) {
+	step := moveSpeed / 100 * p.speedScale()
+	if in.movingX == -1 && p.pos.X-p.radius >= p.bounds.Min.X || in.movingX == 1 && p.pos.X+p.radius <= p.bounds.Max.X {
+		next := pixel.V(p.pos.X+float64(in.movingX)*step, p.pos.Y)
+		if arena == nil || !arena.CircleBlocked(next, p.radius) {
+			p.pos = next
+		}
+	}
+	if in.movingY == -1 && p.pos.Y-p.radius >= p.bounds.Min.Y || in.movingY == 1 && p.pos.Y+p.radius <= p.bounds.Max.Y {
+		next := pixel.V(p.pos.X, p.pos.Y+float64(in.movingY)*step)
+		if arena == nil || !arena.CircleBlocked(next, p.radius) {
+			p.pos = next
+		}
+	}
+}
+
+// predictInput applies the input locally right away and remembers it until
+// the server acknowledges it.
+func (p *Player) predictInput(movingX, movingY int) pendingInput {
+	inputSeq++
+	in := pendingInput{seq: inputSeq, movingX: movingX, movingY: movingY}
+	pendingInputs = append(pendingInputs, in)
+	p.applyInput(in)
+	return in
+}
+
+// reconcile snaps the player to the authoritative position and replays the
+// inputs the server hasn't processed yet on top of it.
+func (p *Player) reconcile(pos pixel.Vec, lastInput uint32) {
+	p.pos = pos
+	kept := pendingInputs[:0]
+	for _, in := range pendingInputs {
+		if in.seq > lastInput {
+			p.applyInput(in)
+			kept = append(kept, in)
+		}
+	}
+	pendingInputs = kept
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/imdraw"
+	"github.com/gopxl/pixel/pixelgl"
+	"github.com/gopxl/pixel/text"
+)
+
+type SafeZoneState struct {
+	Current   CircleState `json:"current"`
+	Next      CircleState `json:"next"`
+	Phase     int         `json:"phase"`
+	Shrinking bool        `json:"shrinking"`
+	Countdown float64     `json:"countdown"`
+	Damage    float64     `json:"damage"`
+	// Where the shrink started and how long it takes
+	Start      CircleState `json:"start"`
+	ShrinkTime float64     `json:"shrinkTime"`
+
+	received time.Time
+}
+
+var safeZone *SafeZoneState
+var szmu sync.Mutex
+var safeZoneImd = imdraw.New(nil)
+
+func handleSafeZone(msg Message) {
+	var state SafeZoneState
+	data, err := json.Marshal(msg.Content)
+	if err != nil {
+		log.Printf("Error marshaling safe zone: %v", err)
+		return
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("Error unmarshaling safe zone: %v", err)
+		return
+	}
+	state.received = time.Now()
+	szmu.Lock()
+	safeZone = &state
+	szmu.Unlock()
+}
+
+// now works out where the zone is at this moment. The server only sends it
+// when a phase starts or ends and about once a second, so the shrink and the
+// countdown carry on here in between.
+func (z SafeZoneState) now() SafeZoneState {
+	z.Countdown = math.Max(0, z.Countdown-time.Since(z.received).Seconds())
+	if z.Shrinking && z.ShrinkTime > 0 {
+		t := math.Min(1, 1-z.Countdown/z.ShrinkTime)
+		z.Current = CircleState{
+			X:      z.Start.X + (z.Next.X-z.Start.X)*t,
+			Y:      z.Start.Y + (z.Next.Y-z.Start.Y)*t,
+			Radius: z.Start.Radius + (z.Next.Radius-z.Start.Radius)*t,
+		}
+	}
+	return z
+}
+
+func handleBattleRoyaleWon(msg Message) {
+	content, _ := msg.Content.(map[string]interface{})
+	winner, _ := content["winner"].(float64)
+	if int(winner) == 0 {
+		showTeamBanner("Nobody survived")
+		return
+	}
+	showTeamBanner(fmt.Sprintf("%s won the battle royale", playerName(int(winner))))
+}
+
+// DrawSafeZone draws the current safe circle and where it will shrink to.
+func DrawSafeZone(win *pixelgl.Window) {
+	szmu.Lock()
+	defer szmu.Unlock()
+	if safeZone == nil {
+		return
+	}
+	zone := safeZone.now()
+	safeZoneImd.Clear()
+	safeZoneImd.Color = pixel.RGB(1, 1, 1)
+	safeZoneImd.Push(pixel.V(zone.Next.X, zone.Next.Y))
+	safeZoneImd.Circle(zone.Next.Radius, 1)
+	safeZoneImd.Color = pixel.RGB(0.2, 0.4, 1)
+	safeZoneImd.Push(pixel.V(zone.Current.X, zone.Current.Y))
+	safeZoneImd.Circle(zone.Current.Radius, 4)
+	safeZoneImd.Draw(win)
+}
+
+// DrawSafeZoneHUD shows the zone countdown and warns when we're outside it.
+func DrawSafeZoneHUD(win *pixelgl.Window, pos pixel.Vec) {
+	szmu.Lock()
+	defer szmu.Unlock()
+	if safeZone == nil {
+		return
+	}
+	zone := safeZone.now()
+	txt := text.New(pixel.V(win.Bounds().W()/2-80, win.Bounds().H()-40), hudAtlas)
+	txt.Color = pixel.RGB(1, 1, 1)
+	if zone.Shrinking {
+		fmt.Fprintf(txt, "Zone shrinking: %.0fs\n", zone.Countdown)
+	} else {
+		fmt.Fprintf(txt, "Zone shrinks in %.0fs\n", zone.Countdown)
+	}
+	if pos.To(pixel.V(zone.Current.X, zone.Current.Y)).Len() > zone.Current.Radius {
+		txt.Color = pixel.RGB(1, 0.2, 0.2)
+		fmt.Fprintf(txt, "Outside the zone! -%.0f HP/s", zone.Damage)
+	}
+	txt.Draw(win, pixel.IM)
+}
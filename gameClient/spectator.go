@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/pixelgl"
+	"github.com/gopxl/pixel/text"
+	"github.com/gorilla/websocket"
+)
+
+type SpectateTarget struct {
+	ID       int     `json:"id"`
+	Nickname string  `json:"nickname"`
+	Team     int     `json:"team"`
+	PosX     float64 `json:"posX"`
+	PosY     float64 `json:"posY"`
+}
+
+var spectateTargets []SpectateTarget
+var spmu sync.Mutex
+
+const spectatorCameraSpeed = 900.0 // free camera, pixels per second
+
+func handleSpectateTargets(msg Message) {
+	var targets []SpectateTarget
+	data, err := json.Marshal(msg.Content)
+	if err != nil {
+		log.Printf("Error marshaling spectate targets: %v", err)
+		return
+	}
+	if err := json.Unmarshal(data, &targets); err != nil {
+		log.Printf("Error unmarshaling spectate targets: %v", err)
+		return
+	}
+	spmu.Lock()
+	spectateTargets = targets
+	spmu.Unlock()
+}
+
+// nextTarget returns the player step places after current in the list,
+// wrapping around. Returns 0 when there's nobody to follow.
+func nextTarget(targets []SpectateTarget, current, step int) int {
+	if len(targets) == 0 {
+		return 0
+	}
+	index := -1
+	for i, target := range targets {
+		if target.ID == current {
+			index = i
+		}
+	}
+	if index == -1 && step < 0 {
+		index = 0
+	}
+	index = ((index+step)%len(targets) + len(targets)) % len(targets)
+	return targets[index].ID
+}
+
+// followPosition is where the followed player is drawn, falling back to the
+// server's list when they're outside our area of interest.
+func followPosition(targets []SpectateTarget, id int) (pixel.Vec, string, bool) {
+	for _, target := range targets {
+		if target.ID != id {
+			continue
+		}
+		pos := pixel.V(target.PosX, target.PosY)
+		mu.Lock()
+		if other, ok := otherPlayers[id]; ok {
+			if sampled, ok := other.snapshots.Sample(renderTime()); ok {
+				pos = sampled
+			}
+		}
+		mu.Unlock()
+		return pos, target.Nickname, true
+	}
+	return pixel.ZV, "", false
+}
+
+// runSpectator watches the match without a player. Tab and the arrow keys
+// cycle through players to follow, F toggles a free camera moved with WASD.
+func runSpectator(win *pixelgl.Window, conn *websocket.Conn) {
+	if err := conn.WriteJSON(Message{ClientID: playerID, Type: "spectate"}); err != nil {
+		log.Println("spectate write:", err)
+		return
+	}
+	playerExists = false
+	follow, free := 0, false
+	camPos := worldBounds.Center()
+	var sentPos pixel.Vec
+	viewSent := false
+	lastTime := time.Now()
+
+	viewTicker := time.NewTicker(time.Second / 20)
+	defer viewTicker.Stop()
+	pingTicker := time.NewTicker(pingInterval)
+	defer pingTicker.Stop()
+
+	for !win.Closed() {
+		time.Sleep(time.Second / fps)
+		typing, err := updateChatInput(win, conn)
+		if err != nil {
+			log.Println("chat write:", err)
+			return
+		}
+		if !typing && win.JustPressed(pixelgl.KeyEscape) {
+			return
+		}
+		currentTime := time.Now()
+		dt = currentTime.Sub(lastTime).Seconds()
+		lastTime = currentTime
+
+		for pending := true; pending; {
+			select {
+			case msg := <-receive:
+				HandleMessage(msg, nil)
+			default:
+				pending = false
+			}
+		}
+
+		spmu.Lock()
+		targets := append([]SpectateTarget(nil), spectateTargets...)
+		spmu.Unlock()
+
+		switch {
+		case typing:
+		case win.JustPressed(pixelgl.KeyTab) || win.JustPressed(pixelgl.KeyRight):
+			follow, free = nextTarget(targets, follow, 1), false
+		case win.JustPressed(pixelgl.KeyLeft):
+			follow, free = nextTarget(targets, follow, -1), false
+		case win.JustPressed(pixelgl.KeyF):
+			free = !free
+		}
+
+		label := "Free camera"
+		if !free {
+			pos, nickname, ok := followPosition(targets, follow)
+			if !ok {
+				// Our target died or left, move on to someone else
+				follow = nextTarget(targets, follow, 1)
+				pos, nickname, ok = followPosition(targets, follow)
+			}
+			if ok {
+				camPos = pos
+				label = "Following " + nickname
+			}
+		}
+		if !typing && (free || follow == 0) {
+			var move pixel.Vec
+			if win.Pressed(pixelgl.KeyW) {
+				move.Y++
+			}
+			if win.Pressed(pixelgl.KeyS) {
+				move.Y--
+			}
+			if win.Pressed(pixelgl.KeyA) {
+				move.X--
+			}
+			if win.Pressed(pixelgl.KeyD) {
+				move.X++
+			}
+			camPos = camPos.Add(move.Scaled(spectatorCameraSpeed * dt))
+			camPos = pixel.V(
+				pixel.Clamp(camPos.X, worldBounds.Min.X, worldBounds.Max.X),
+				pixel.Clamp(camPos.Y, worldBounds.Min.Y, worldBounds.Max.Y),
+			)
+		}
+
+		select {
+		case <-viewTicker.C:
+			// Only when the camera moved, the server keeps the last one
+			if !viewSent || camPos != sentPos {
+				err = conn.WriteJSON(Message{ClientID: playerID, Type: "spectator_view", Content: map[string]float64{"X": camPos.X, "Y": camPos.Y}})
+				sentPos, viewSent = camPos, true
+			}
+		case <-pingTicker.C:
+			err = sendPing(conn)
+		default:
+		}
+		if err != nil {
+			log.Println("spectator write:", err)
+			return
+		}
+
+		win.Clear(pixel.RGB(0.1, 0.1, 0.1))
+		win.SetMatrix(cameraMatrix(win, camPos))
+		DrawWorld(win)
+		DrawArena(win)
+		DrawSafeZone(win)
+		DrawZones(win)
+		DrawFlags(win)
+		DrawOtherPlayers(win)
+		DrawMonsters(win)
+		DrawProjectiles(win)
+		DrawExplosions(win)
+		DrawMeleeEffects(win)
+		win.SetMatrix(pixel.IM)
+		DrawPing(win)
+		DrawWaveHUD(win)
+		DrawTeamHUD(win)
+		DrawZoneHUD(win)
+		DrawMatchHUD(win)
+
+		txt := text.New(pixel.V(10, 20), hudAtlas)
+		txt.Color = pixel.RGB(1, 1, 1)
+		fmt.Fprintf(txt, "Spectating: %s  (Tab/arrows: next player, F: free camera, Esc: menu)", label)
+		txt.Draw(win, pixel.IM)
+		DrawChat(win)
+		win.Update()
+	}
+}
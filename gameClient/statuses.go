@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/imdraw"
+)
+
+type StatusEffect struct {
+	Kind      string
+	Magnitude float64
+	Stacks    int
+	Remaining float64
+}
+
+// statusesFrom reads the active effects out of a player snapshot.
+func statusesFrom(state PlayerState) []StatusEffect {
+	list, _ := state["statuses"].([]interface{})
+	var statuses []StatusEffect
+	for _, item := range list {
+		s, _ := item.(map[string]interface{})
+		kind, _ := s["kind"].(string)
+		magnitude, _ := s["magnitude"].(float64)
+		stacks, _ := s["stacks"].(float64)
+		remaining, _ := s["remaining"].(float64)
+		statuses = append(statuses, StatusEffect{Kind: kind, Magnitude: magnitude, Stacks: int(stacks), Remaining: remaining})
+	}
+	return statuses
+}
+
+func (p *Player) status(kind string) (StatusEffect, bool) {
+	for _, s := range p.statuses {
+		if s.Kind == kind {
+			return s, true
+		}
+	}
+	return StatusEffect{}, false
+}
+
+// speedScale mirrors the server's statusSpeedScale so prediction agrees with
+// it while we're slowed or stunned.
+func (p *Player) speedScale() float64 {
+	if _, stunned := p.status("stun"); stunned {
+		return 0
+	}
+	if slow, ok := p.status("slow"); ok {
+		return math.Max(0, 1-slow.Magnitude)
+	}
+	return 1
+}
+
+// drawStatuses adds an indicator around the player for every active effect.
+func (p *Player) drawStatuses(imd *imdraw.IMDraw) {
+	t := float64(time.Now().UnixMilli()) / 1000
+	for _, s := range p.statuses {
+		switch s.Kind {
+		case "shield":
+			imd.Color = pixel.RGBA{R: 0.4, G: 0.9, B: 1, A: 0.8}
+			imd.Push(p.pos)
+			imd.Circle(p.radius+8, 3)
+		case "slow":
+			imd.Color = pixel.RGB(0.5, 0.7, 1)
+			imd.Push(p.pos)
+			imd.Circle(p.radius-2, 2)
+		case "burn":
+			// Flames flickering around the player, one per stack
+			imd.Color = pixel.RGB(1, 0.5, 0)
+			for i := 0; i < s.Stacks; i++ {
+				angle := t*3 + float64(i)*2*math.Pi/float64(s.Stacks)
+				flicker := 3 + math.Sin(t*20+float64(i))
+				imd.Push(p.pos.Add(pixel.V(math.Cos(angle), math.Sin(angle)).Scaled(p.radius + 2)))
+				imd.Circle(flicker, 0)
+			}
+		case "stun":
+			// Stars circling over the player's head
+			imd.Color = pixel.RGB(1, 1, 0.2)
+			head := p.pos.Add(pixel.V(0, p.radius+18))
+			for i := 0; i < 3; i++ {
+				angle := t*4 + float64(i)*2*math.Pi/3
+				imd.Push(head.Add(pixel.V(math.Cos(angle)*10, math.Sin(angle)*3)))
+				imd.Circle(2.5, 0)
+			}
+		}
+	}
+}
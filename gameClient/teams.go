@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/pixelgl"
+	"github.com/gopxl/pixel/text"
+)
+
+var teamColors = map[int]pixel.RGBA{
+	1: pixel.RGB(1, 0.5, 0),   // orange
+	2: pixel.RGB(0, 0.8, 0.8), // cyan
+	3: pixel.RGB(0.8, 0, 0.8), // purple
+	4: pixel.RGB(0.9, 0.9, 0), // yellow
+}
+
+// Team deathmatch status from the server
+var (
+	playerTeam    int
+	teamScores    []int
+	teamLimit     int
+	teamBanner    string
+	teamBannerEnd time.Time
+)
+
+func teamStateFrom(state PlayerState) int {
+	if team, ok := state["team"].(float64); ok {
+		return int(team)
+	}
+	return 0
+}
+
+func handleTeamMessage(msg Message) {
+	content, _ := msg.Content.(map[string]interface{})
+	scores, _ := content["scores"].([]interface{})
+	teamScores = teamScores[:0]
+	for _, score := range scores {
+		if s, ok := score.(float64); ok {
+			teamScores = append(teamScores, int(s))
+		}
+	}
+	switch msg.Type {
+	case "team_score":
+		if limit, ok := content["limit"].(float64); ok {
+			teamLimit = int(limit)
+		}
+	case "team_won":
+		team, _ := content["team"].(float64)
+		if int(team) == playerTeam {
+			showTeamBanner("Your team won!")
+		} else {
+			showTeamBanner(fmt.Sprintf("Team %d won", int(team)))
+		}
+	}
+}
+
+func showTeamBanner(banner string) {
+	teamBanner = banner
+	teamBannerEnd = time.Now().Add(5 * time.Second)
+}
+
+// DrawTeamHUD shows the latest objective announcement and each team's score
+// against the limit.
+func DrawTeamHUD(win *pixelgl.Window) {
+	if time.Now().Before(teamBannerEnd) {
+		banner := text.New(pixel.ZV, hudAtlas)
+		banner.Color = pixel.RGB(1, 1, 1)
+		if color, ok := teamColors[playerTeam]; ok {
+			banner.Color = color
+		}
+		fmt.Fprint(banner, teamBanner)
+		pos := win.Bounds().Center().Add(pixel.V(-banner.Bounds().W(), 100))
+		banner.Draw(win, pixel.IM.Scaled(pixel.ZV, 2).Moved(pos))
+	}
+	if len(teamScores) == 0 {
+		return
+	}
+	txt := text.New(pixel.V(win.Bounds().W()-140, win.Bounds().H()-20), hudAtlas)
+	for i, score := range teamScores {
+		team := i + 1
+		txt.Color = teamColors[team]
+		marker := ""
+		if team == playerTeam {
+			marker = " (you)"
+		}
+		fmt.Fprintf(txt, "Team %d: %d/%d%s\n", team, score, teamLimit, marker)
+	}
+	txt.Draw(win, pixel.IM)
+}
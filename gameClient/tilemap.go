@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/imdraw"
+	"github.com/gopxl/pixel/pixelgl"
+)
+
+const (
+	TileWalkable   = 0
+	TileSolid      = 1
+	TileDecorative = 2
+)
+
+// TileMap mirrors the server's map format. Tiles are stored row by row from
+// the bottom row up.
+type TileMap struct {
+	Width    int         `json:"width"`
+	Height   int         `json:"height"`
+	TileSize float64     `json:"tileSize"`
+	Tiles    []int       `json:"tiles"`
+	Spawns   []pixel.Vec `json:"spawns"`
+
+	Pickups    []pixel.Vec `json:"pickups,omitempty"`
+	Objectives []pixel.Vec `json:"objectives,omitempty"`
+}
+
+// Map received from the server on join, nil until then.
+var arena *TileMap
+var arenaImd *imdraw.IMDraw
+
+func setArena(m *TileMap) {
+	arena = m
+	worldBounds = pixel.R(0, 0, float64(m.Width)*m.TileSize, float64(m.Height)*m.TileSize)
+	arenaImd = nil // rebuilt on the next draw
+}
+
+func (m *TileMap) TileAt(x, y float64) int {
+	tx := int(math.Floor(x / m.TileSize))
+	ty := int(math.Floor(y / m.TileSize))
+	if tx < 0 || ty < 0 || tx >= m.Width || ty >= m.Height {
+		return TileSolid
+	}
+	return m.Tiles[ty*m.Width+tx]
+}
+
+// CircleBlocked is the same test the server uses to stop movement, so
+// prediction slides along walls exactly like the server does.
+func (m *TileMap) CircleBlocked(pos pixel.Vec, radius float64) bool {
+	minX := int(math.Floor((pos.X - radius) / m.TileSize))
+	maxX := int(math.Floor((pos.X + radius) / m.TileSize))
+	minY := int(math.Floor((pos.Y - radius) / m.TileSize))
+	maxY := int(math.Floor((pos.Y + radius) / m.TileSize))
+	for tx := minX; tx <= maxX; tx++ {
+		for ty := minY; ty <= maxY; ty++ {
+			if m.TileAt((float64(tx)+0.5)*m.TileSize, (float64(ty)+0.5)*m.TileSize) != TileSolid {
+				continue
+			}
+			nearX := math.Max(float64(tx)*m.TileSize, math.Min(pos.X, float64(tx+1)*m.TileSize))
+			nearY := math.Max(float64(ty)*m.TileSize, math.Min(pos.Y, float64(ty+1)*m.TileSize))
+			if math.Hypot(pos.X-nearX, pos.Y-nearY) < radius {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var tileColors = map[int]pixel.RGBA{
+	TileSolid:      pixel.RGB(0.3, 0.3, 0.35),
+	TileDecorative: pixel.RGB(0.1, 0.4, 0.3),
+}
+
+// DrawArena draws the tiles. The map never changes during a match, so the
+// shapes are built once and reused every frame.
+func DrawArena(win *pixelgl.Window) {
+	if arena == nil {
+		return
+	}
+	if arenaImd == nil {
+		arenaImd = imdraw.New(nil)
+		drawTiles(arenaImd, arena)
+	}
+	arenaImd.Draw(win)
+}
+
+func drawTiles(imd *imdraw.IMDraw, m *TileMap) {
+	for i, tile := range m.Tiles {
+		color, ok := tileColors[tile]
+		if !ok {
+			continue
+		}
+		x, y := float64(i%m.Width)*m.TileSize, float64(i/m.Width)*m.TileSize
+		imd.Color = color
+		imd.Push(pixel.V(x, y), pixel.V(x+m.TileSize, y+m.TileSize))
+		imd.Rectangle(0)
+	}
+}
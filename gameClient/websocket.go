@@ -14,6 +14,8 @@ import (
 type Message struct {
 	ClientID int         `json:"client_id"`
 	Type     string      `json:"type"`
+	Tick     uint64      `json:"tick,omitempty"`
+	Time     int64       `json:"time,omitempty"`
 	Content  interface{} `json:"content"`
 }
 
@@ -21,16 +23,28 @@ type OtherPlayer struct {
 	Player      *Player
 	LastSeen    time.Time
 	IsAttacking bool
+	snapshots   snapshotBuffer
 }
 type ProjectileState struct {
 	PosX float64 `json:"posX"`
 	PosY float64 `json:"posY"`
 }
+type EntityEvent struct {
+	Kind string `json:"kind"`
+	ID   int    `json:"id"`
+}
 type CircleState struct {
 	X, Y   float64
 	Radius float64
 }
 
+// MeleeState is a swing covering Width radians around Direction.
+type MeleeState struct {
+	CircleState
+	Direction float64
+	Width     float64
+}
+
 var stopPlaying bool
 var explosions = make(map[*Explosion]bool)
 var meleeAttacks = make(map[*MeleeEffect]bool)
@@ -39,6 +53,10 @@ var otherPlayers = make(map[int]*OtherPlayer)
 var projectiles = make(map[int]Projectile)
 var nextExplosionID, nextMeleeID int
 
+// Tick of the last players snapshot, sent with attacks so the server can
+// check hits against what we were seeing.
+var lastSnapshotTick uint64
+
 type PlayerState map[string]interface{}
 
 var mu, emu, pmu, mmu sync.Mutex
@@ -77,9 +95,20 @@ func HandleMessage(msg Message, player *Player) {
 				startY = y
 			}
 
+			if team, ok := content["team"].(float64); ok {
+				playerTeam = int(team)
+			}
+			if speed, ok := content["speed"].(float64); ok {
+				moveSpeed = speed
+			}
 			if hp, ok := content["HP"].(int); ok {
 				playerHP = hp
 			}
+			width, okW := content["worldWidth"].(float64)
+			height, okH := content["worldHeight"].(float64)
+			if okW && okH {
+				worldBounds = pixel.R(0, 0, width, height)
+			}
 
 		}
 
@@ -98,7 +127,9 @@ func HandleMessage(msg Message, player *Player) {
 			return
 		}
 
+		received := snapshotTime(msg)
 		mu.Lock()
+		lastSnapshotTick = msg.Tick
 		for id, state := range statePlayers {
 
 			pos := pixel.V(0, 0)
@@ -126,13 +157,25 @@ func HandleMessage(msg Message, player *Player) {
 			if hp, ok := state["health"].(float64); ok {
 				health = int(hp)
 			}
-			if id == playerID {
-				playerHP = health
-			}
 			var heroClass int
 			if hc, ok := state["heroClass"].(float64); ok {
 				heroClass = int(hc)
 			}
+			// Our own player is predicted locally, only correct it here
+			if id == playerID {
+				playerHP = health
+				if player != nil {
+					var lastInput uint32
+					if li, ok := state["lastInput"].(float64); ok {
+						lastInput = uint32(li)
+					}
+					player.health = health
+					player.team = teamStateFrom(state)
+					player.statuses = statusesFrom(state)
+					player.reconcile(pos, lastInput)
+				}
+				continue
+			}
 			// Create or update other player
 			other, exists := otherPlayers[id]
 			if !exists {
@@ -142,6 +185,7 @@ func HandleMessage(msg Message, player *Player) {
 					// speed:  0.3,
 					radius: 15,
 					health: health,
+					pos:    pos,
 				}
 				other = &OtherPlayer{
 					Player:   newPlayer,
@@ -149,17 +193,49 @@ func HandleMessage(msg Message, player *Player) {
 				}
 			}
 
-			other.Player.pos = pos
+			other.snapshots.Push(received, pos)
 			other.Player.direction = dir.Sub(pos).Unit()
 			// log.Println(dir.Sub(pos).Unit())
 			other.Player.nickname = nickname
 			other.Player.heroClass = heroClass
+			other.Player.team = teamStateFrom(state)
+			other.Player.statuses = statusesFrom(state)
 
 			otherPlayers[id] = other
 
 		}
 		mu.Unlock()
 
+	case "entity_enter", "entity_leave":
+		var event EntityEvent
+		data, err := json.Marshal(msg.Content)
+		if err != nil {
+			log.Printf("Error marshaling entity event: %v", err)
+			return
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Printf("Error unmarshaling entity event: %v", err)
+			return
+		}
+		if event.Kind != "player" || event.ID == playerID {
+			return
+		}
+		mu.Lock()
+		if msg.Type == "entity_enter" {
+			if _, exists := otherPlayers[event.ID]; !exists {
+				otherPlayers[event.ID] = &OtherPlayer{
+					Player: &Player{
+						ID:     event.ID,
+						imd:    imdraw.New(nil),
+						radius: 15,
+					},
+					LastSeen: time.Now(),
+				}
+			}
+		} else {
+			delete(otherPlayers, event.ID)
+		}
+		mu.Unlock()
 	case "player_left":
 		// Handle player leaving messages
 		mu.Lock()
@@ -185,27 +261,28 @@ func HandleMessage(msg Message, player *Player) {
 			return
 		}
 
+		received := snapshotTime(msg)
 		for id, state := range projStates {
 			pmu.Lock()
 			proj, exists := projectiles[id]
 			if !exists {
-				projectiles[id] = Projectile{
+				proj = Projectile{
 					imd: imdraw.New(nil),
 					pos: pixel.V(state.PosX, state.PosY),
 				}
-
-			} else {
-				proj.pos = pixel.V(state.PosX, state.PosY)
-				projectiles[id] = proj
 			}
+			proj.snapshots.Push(received, pixel.V(state.PosX, state.PosY))
+			projectiles[id] = proj
 			pmu.Unlock()
 
 		}
 		pmu.Lock()
-		for id := range projectiles {
+		for id, proj := range projectiles {
 			_, exist := projStates[id]
-			if !exist {
-				delete(projectiles, id)
+			if !exist && proj.removedAt.IsZero() {
+				// Keep drawing it until the render time catches up
+				proj.removedAt = received
+				projectiles[id] = proj
 			}
 		}
 		pmu.Unlock()
@@ -231,7 +308,7 @@ func HandleMessage(msg Message, player *Player) {
 		emu.Unlock()
 		nextExplosionID++
 	case "melee_state":
-		var meleeState CircleState
+		var meleeState MeleeState
 		data, err := json.Marshal(msg.Content)
 		if err != nil {
 			log.Printf("Error marshaling blow state: %v", err)
@@ -247,6 +324,60 @@ func HandleMessage(msg Message, player *Player) {
 		meleeAttacks[melee] = true
 		mmu.Unlock()
 		nextMeleeID++
+	case "map_data":
+		var m TileMap
+		data, err := json.Marshal(msg.Content)
+		if err != nil {
+			log.Printf("Error marshaling map: %v", err)
+			return
+		}
+		if err := json.Unmarshal(data, &m); err != nil {
+			log.Printf("Error unmarshaling map: %v", err)
+			return
+		}
+		setArena(&m)
+		log.Printf("Loaded %dx%d map", m.Width, m.Height)
+	case "monsters_update":
+		handleMonstersUpdate(msg)
+	case "monster_died":
+		content, _ := msg.Content.(map[string]interface{})
+		if id, ok := content["id"].(float64); ok {
+			monmu.Lock()
+			delete(monsters, int(id))
+			monmu.Unlock()
+		}
+	case "wave_started", "wave_cleared", "match_over":
+		handleWaveMessage(msg)
+	case "team_score", "team_won":
+		handleTeamMessage(msg)
+	case "match_joined", "spectating":
+		playerID = msg.ClientID
+	case "spectate_targets":
+		handleSpectateTargets(msg)
+	case "match_state":
+		handleMatchState(msg)
+	case "queue_status":
+		handleQueueStatus(msg)
+	case "chat_message":
+		handleChatMessage(msg)
+	case "safe_zone":
+		handleSafeZone(msg)
+	case "br_won":
+		handleBattleRoyaleWon(msg)
+	case "zones_update":
+		handleZonesUpdate(msg)
+	case "zone_captured", "koth_won":
+		handleZoneEvent(msg)
+	case "flags_update":
+		handleFlagsUpdate(msg)
+	case "flag_taken", "flag_captured", "flag_dropped", "flag_returned":
+		handleFlagEvent(msg)
+	case "damage":
+		handleDamageEvent(msg)
+	case "ability_state":
+		handleAbilityState(msg)
+	case "pong":
+		handlePong(msg)
 	case "player_died":
 		mu.Lock()
 		if _, exists := otherPlayers[msg.ClientID]; exists {
@@ -269,39 +400,34 @@ func HandleMessage(msg Message, player *Player) {
 
 }
 
+// Players are added and removed by the server's entity_enter/entity_leave
+// events as they move in and out of our area of interest.
 func DrawOtherPlayers(win *pixelgl.Window) {
-	currentTime := time.Now()
-	var stalePlayers []int
-
 	mu.Lock()
 	defer mu.Unlock()
 
-	// First pass: identify stale players and draw active ones
-	for id, other := range otherPlayers {
-
-		if currentTime.Sub(other.LastSeen) > time.Second {
-			stalePlayers = append(stalePlayers, id)
-			continue
+	at := renderTime()
+	for _, other := range otherPlayers {
+		pos, ok := other.snapshots.Sample(at)
+		if !ok {
+			continue // entered but no state received yet
 		}
+		other.Player.pos = pos
 		other.Player.Draw(win)
-		other.LastSeen = time.Now()
-	}
-
-	// Second pass: remove stale players
-	for id := range stalePlayers {
-		if other, exists := otherPlayers[id]; exists {
-			if other.Player != nil && other.Player.imd != nil {
-				other.Player.imd.Clear()
-			}
-			delete(otherPlayers, id)
-			log.Printf("Removed stale player: %d", id)
-		}
 	}
 }
 
 func DrawProjectiles(win *pixelgl.Window) {
+	at := renderTime()
 	pmu.Lock()
-	for _, proj := range projectiles {
+	for id, proj := range projectiles {
+		if !proj.removedAt.IsZero() && at.After(proj.removedAt) {
+			delete(projectiles, id)
+			continue
+		}
+		if pos, ok := proj.snapshots.Sample(at); ok {
+			proj.pos = pos
+		}
 		proj.Draw(win)
 	}
 	pmu.Unlock()
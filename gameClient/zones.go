@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gopxl/pixel"
+	"github.com/gopxl/pixel/imdraw"
+	"github.com/gopxl/pixel/pixelgl"
+	"github.com/gopxl/pixel/text"
+)
+
+type ZoneState struct {
+	ID        int         `json:"id"`
+	Circle    CircleState `json:"circle"`
+	Owner     int         `json:"owner"`
+	Progress  float64     `json:"progress"`
+	Contested bool        `json:"contested"`
+}
+
+type ZonesUpdate struct {
+	Zones    []ZoneState        `json:"zones"`
+	Scores   map[string]float64 `json:"scores"`
+	Limit    float64            `json:"limit"`
+	ByTeam   bool               `json:"byTeam"`
+	RotateIn float64            `json:"rotateIn"`
+}
+
+var koth ZonesUpdate
+var zmu sync.Mutex
+var zoneImd = imdraw.New(nil)
+
+func handleZonesUpdate(msg Message) {
+	var update ZonesUpdate
+	data, err := json.Marshal(msg.Content)
+	if err != nil {
+		log.Printf("Error marshaling zones: %v", err)
+		return
+	}
+	if err := json.Unmarshal(data, &update); err != nil {
+		log.Printf("Error unmarshaling zones: %v", err)
+		return
+	}
+	zmu.Lock()
+	koth = update
+	zmu.Unlock()
+}
+
+func handleZoneEvent(msg Message) {
+	content, _ := msg.Content.(map[string]interface{})
+	switch msg.Type {
+	case "zone_captured":
+		owner, _ := content["owner"].(float64)
+		showTeamBanner(fmt.Sprintf("%s captured a zone", sideName(int(owner), koth.ByTeam)))
+	case "koth_won":
+		winner, _ := content["winner"].(float64)
+		byTeam, _ := content["byTeam"].(bool)
+		showTeamBanner(fmt.Sprintf("%s won the hill", sideName(int(winner), byTeam)))
+	}
+}
+
+// sideName describes who holds a zone: a team, or a player.
+func sideName(side int, byTeam bool) string {
+	if byTeam {
+		if side == playerTeam {
+			return "Your team"
+		}
+		return fmt.Sprintf("Team %d", side)
+	}
+	return playerName(side)
+}
+
+func sideColor(side int, byTeam bool) pixel.RGBA {
+	switch {
+	case side == 0:
+		return pixel.RGB(0.8, 0.8, 0.8)
+	case byTeam:
+		return teamColors[side]
+	case side == playerID:
+		return pixel.RGB(0, 1, 0)
+	default:
+		return pixel.RGB(1, 0, 0)
+	}
+}
+
+// DrawZones draws each capture zone with a ring showing its owner's capture
+// progress.
+func DrawZones(win *pixelgl.Window) {
+	zmu.Lock()
+	state := koth
+	zmu.Unlock()
+
+	zoneImd.Clear()
+	for _, zone := range state.Zones {
+		center := pixel.V(zone.Circle.X, zone.Circle.Y)
+		zoneImd.Color = pixel.RGB(0.8, 0.8, 0.8)
+		if zone.Contested {
+			zoneImd.Color = pixel.RGB(1, 1, 0)
+		}
+		zoneImd.Push(center)
+		zoneImd.Circle(zone.Circle.Radius, 3)
+
+		if zone.Owner != 0 && zone.Progress > 0 {
+			zoneImd.Color = sideColor(zone.Owner, state.ByTeam)
+			zoneImd.Push(center)
+			zoneImd.CircleArc(zone.Circle.Radius+8, math.Pi/2, math.Pi/2-2*math.Pi*zone.Progress, 6)
+		}
+	}
+	zoneImd.Draw(win)
+}
+
+// DrawZoneHUD lists the king of the hill scores and the time until the zones
+// move.
+func DrawZoneHUD(win *pixelgl.Window) {
+	zmu.Lock()
+	state := koth
+	zmu.Unlock()
+	if len(state.Zones) == 0 {
+		return
+	}
+
+	sides := make([]int, 0, len(state.Scores))
+	for key := range state.Scores {
+		if side, err := strconv.Atoi(key); err == nil {
+			sides = append(sides, side)
+		}
+	}
+	sort.Slice(sides, func(i, j int) bool {
+		return state.Scores[strconv.Itoa(sides[i])] > state.Scores[strconv.Itoa(sides[j])]
+	})
+
+	txt := text.New(pixel.V(win.Bounds().W()-180, win.Bounds().H()-20), hudAtlas)
+	txt.Color = pixel.RGB(1, 1, 1)
+	fmt.Fprintf(txt, "Zones move in %.0fs\n", state.RotateIn)
+	for _, side := range sides {
+		txt.Color = sideColor(side, state.ByTeam)
+		fmt.Fprintf(txt, "%s: %.0f/%.0f\n", sideName(side, state.ByTeam), state.Scores[strconv.Itoa(side)], state.Limit)
+	}
+	txt.Draw(win, pixel.IM)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"time"
+)
+
+// Inputs abilities are bound to on the client
+const (
+	InputPrimary   = "primary"   // left mouse button
+	InputSecondary = "secondary" // right mouse button
+	InputShift     = "shift"
+	InputQ         = "q"
+)
+
+// Ability effects, see abilityEffects
+const (
+	EffectProjectile = "projectile" // fires the class projectile
+	EffectMelee      = "melee"      // hits everyone within Range of the caster
+	EffectDash       = "dash"       // moves up to Range towards the aim, then hits within Radius
+	EffectBlink      = "blink"      // teleports up to Range towards the aim
+	EffectBlast      = "blast"      // explodes with Radius at the aim, up to Range away
+	EffectBuff       = "buff"       // only applies SelfStatus
+)
+
+type Ability struct {
+	Name     string
+	Input    string
+	Cooldown time.Duration
+	CastTime time.Duration // delay between the input and the effect
+	Range    float64
+	Effect   string
+	Radius   float64
+	Arc      float64 // melee swing in degrees, 0 for all around
+	// Status effects put on everyone the ability hits, and on the caster
+	Status     StatusApply
+	SelfStatus StatusApply
+}
+
+// Each class's primary ability is its old attack, the rest are bound to the
+// other inputs.
+var classAbilities = map[int][]Ability{
+	WarriorClass.ID: {
+		{Name: "Strike", Input: InputPrimary, Cooldown: attackCooldown(WarriorClass), Range: WarriorClass.AttackRange, Arc: WarriorClass.AttackArc, Effect: EffectMelee},
+		{Name: "Whirlwind", Input: InputSecondary, Cooldown: 8 * time.Second, CastTime: 300 * time.Millisecond, Range: 90, Effect: EffectMelee,
+			Status: StatusApply{Kind: StatusSlow, Magnitude: 0.4, Duration: 2 * time.Second}},
+		{Name: "Charge", Input: InputShift, Cooldown: 6 * time.Second, Range: 250, Effect: EffectDash, Radius: 60, Arc: 180,
+			Status: StatusApply{Kind: StatusStun, Duration: time.Second}},
+		{Name: "Guard", Input: InputQ, Cooldown: 15 * time.Second, Effect: EffectBuff,
+			SelfStatus: StatusApply{Kind: StatusShield, Magnitude: 50, Duration: 4 * time.Second}},
+	},
+	MageClass.ID: {
+		{Name: "Fireball", Input: InputPrimary, Cooldown: attackCooldown(MageClass), Range: MageClass.AttackRange, Effect: EffectProjectile},
+		{Name: "Nova", Input: InputSecondary, Cooldown: 8 * time.Second, CastTime: 500 * time.Millisecond, Range: 300, Effect: EffectBlast, Radius: 80,
+			Status: StatusApply{Kind: StatusBurn, Magnitude: 6, Duration: 3 * time.Second}},
+		{Name: "Blink", Input: InputShift, Cooldown: 10 * time.Second, Range: 200, Effect: EffectBlink},
+		{Name: "Barrier", Input: InputQ, Cooldown: 15 * time.Second, Effect: EffectBuff,
+			SelfStatus: StatusApply{Kind: StatusShield, Magnitude: 40, Duration: 4 * time.Second}},
+	},
+}
+
+// maxAbilityTravel is the furthest one ability moves its caster. Their
+// cooldowns are longer than maxRewind, so it's at most one per rewind window.
+func maxAbilityTravel() float64 {
+	var travel float64
+	for _, abilities := range classAbilities {
+		for _, ability := range abilities {
+			if ability.Effect == EffectDash || ability.Effect == EffectBlink {
+				travel = math.Max(travel, ability.Range)
+			}
+		}
+	}
+	return travel
+}
+
+func attackCooldown(class PlayerClass) time.Duration {
+	return time.Duration(class.AttackSpeed) * time.Millisecond
+}
+
+type AbilityCast struct {
+	ID         int     `json:"id"`
+	Input      string  `json:"input"`
+	DirectionX float64 `json:"directionX"` // aim point, like PlayerAttack
+	DirectionY float64 `json:"directionY"`
+	ViewTick   uint64  `json:"viewTick"`
+}
+
+// A cast waiting for its cast time
+type pendingCast struct {
+	cast    AbilityCast
+	ability Ability
+	at      time.Time
+}
+
+// Guarded by mu
+var (
+	abilityReady = make(map[int]map[string]time.Time) // by player, then ability name
+	pendingCasts []pendingCast
+)
+
+func runAbilities() {
+	ticker := time.NewTicker(tickRate)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		mu.Lock()
+		remaining := pendingCasts[:0]
+		for _, p := range pendingCasts {
+			if !canCast(p.cast.ID) {
+				continue // stunned while casting, which interrupts it
+			}
+			if now.Before(p.at) {
+				remaining = append(remaining, p)
+				continue
+			}
+			// Cast times are too long to rewind for, hit what's there now
+			p.cast.ViewTick = serverTick
+			resolveAbility(p.cast, p.ability)
+		}
+		pendingCasts = remaining
+		mu.Unlock()
+	}
+}
+
+// castAbility starts the ability bound to the cast's input if it's off
+// cooldown. Must be called with mu held.
+func castAbility(cast AbilityCast) {
+	state, exists := latestStates[cast.ID]
+	if !exists || !canCast(cast.ID) {
+		return
+	}
+	var ability Ability
+	found := false
+	for _, a := range classAbilities[state.HeroClass] {
+		if a.Input == cast.Input {
+			ability, found = a, true
+		}
+	}
+	if !found {
+		return
+	}
+
+	now := time.Now()
+	ready := abilityReady[cast.ID]
+	if ready == nil {
+		ready = make(map[string]time.Time)
+		abilityReady[cast.ID] = ready
+	}
+	if now.Before(ready[ability.Name]) {
+		return
+	}
+	ready[ability.Name] = now.Add(ability.Cooldown)
+
+	state.DirectionX = cast.DirectionX
+	state.DirectionY = cast.DirectionY
+	state.LastAttack = now
+	setPlayerState(state)
+
+	if ability.CastTime > 0 {
+		pendingCasts = append(pendingCasts, pendingCast{cast: cast, ability: ability, at: now.Add(ability.CastTime)})
+	} else {
+		cast.ViewTick = rewindTick(cast.ViewTick)
+		resolveAbility(cast, ability)
+	}
+	if client := clientByID(cast.ID); client != nil {
+		if err := sendAbilityState(client); err != nil {
+			log.Printf("Error sending abilities to client %d: %v", client.Id, err)
+		}
+	}
+}
+
+// resolveAbility applies the ability's effect. cast.ViewTick is already the
+// tick to check hits against. Must be called with mu held.
+func resolveAbility(cast AbilityCast, ability Ability) {
+	state, alive := latestStates[cast.ID]
+	if !alive {
+		return // died while casting
+	}
+	pos := Vec2D{X: state.PosX, Y: state.PosY}
+	aim := Vec2D{X: cast.DirectionX, Y: cast.DirectionY}
+	log.Printf("%s cast by %d at %v", ability.Name, cast.ID, aim)
+	if ability.SelfStatus.Kind != "" {
+		applyStatus(cast.ID, cast.ID, ability.SelfStatus)
+	}
+
+	var hit []int
+	switch ability.Effect {
+	case EffectProjectile:
+		AddProjectile(cast.ID, pos, aim, ability.Range, cast.ViewTick)
+	case EffectMelee:
+		hit = AddMelee(cast.ID, pos, aim, ability.Range, ability.Arc, cast.ViewTick)
+	case EffectDash:
+		state = travel(state, aim, ability.Range)
+		setPlayerState(state)
+		// Swing the way we dashed, the aim point may be where we stopped
+		end := Vec2D{X: state.PosX, Y: state.PosY}
+		ahead := Vec2D{X: end.X + aim.X - pos.X, Y: end.Y + aim.Y - pos.Y}
+		hit = AddMelee(cast.ID, end, ahead, ability.Radius, ability.Arc, cast.ViewTick)
+	case EffectBlink:
+		setPlayerState(travel(state, aim, ability.Range))
+	case EffectBlast:
+		dx, dy := aim.X-pos.X, aim.Y-pos.Y
+		if dist := math.Hypot(dx, dy); dist > ability.Range {
+			aim = Vec2D{X: pos.X + dx/dist*ability.Range, Y: pos.Y + dy/dist*ability.Range}
+		}
+		hit = SendExplosion(cast.ID, Circle{X: aim.X, Y: aim.Y, Radius: ability.Radius}, cast.ViewTick)
+	}
+	if ability.Status.Kind != "" {
+		for _, id := range hit {
+			applyStatus(id, cast.ID, ability.Status)
+		}
+	}
+}
+
+// travel moves the player up to dist towards target, stopping in front of
+// walls.
+func travel(state PlayerState, target Vec2D, dist float64) PlayerState {
+	dx, dy := target.X-state.PosX, target.Y-state.PosY
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return state
+	}
+	dist = math.Min(dist, length)
+	const step = playerRadius / 2.0
+	for moved := step; moved <= dist; moved += step {
+		next := Vec2D{X: state.PosX + dx/length*step, Y: state.PosY + dy/length*step}
+		if arena.CircleBlocked(next, playerRadius) {
+			break
+		}
+		state.PosX, state.PosY = next.X, next.Y
+	}
+	return state
+}
+
+type AbilityStatus struct {
+	Name     string  `json:"name"`
+	Input    string  `json:"input"`
+	Cooldown float64 `json:"cooldown"` // seconds
+	ReadyIn  float64 `json:"readyIn"`  // seconds, 0 when ready
+}
+
+// sendAbilityState tells the client its abilities and their cooldowns. Must
+// be called with mu held.
+func sendAbilityState(client *Client) error {
+	state, exists := latestStates[client.Id]
+	if !exists {
+		return nil
+	}
+	now := time.Now()
+	var statuses []AbilityStatus
+	for _, a := range classAbilities[state.HeroClass] {
+		statuses = append(statuses, AbilityStatus{
+			Name:     a.Name,
+			Input:    a.Input,
+			Cooldown: a.Cooldown.Seconds(),
+			ReadyIn:  math.Max(0, abilityReady[client.Id][a.Name].Sub(now).Seconds()),
+		})
+	}
+	return client.Conn.WriteJSON(Message{Type: "ability_state", Content: statuses})
+}
+
+func parseAbilityCast(content interface{}) (AbilityCast, error) {
+	var cast AbilityCast
+	data, err := json.Marshal(content)
+	if err != nil {
+		return cast, err
+	}
+	err = json.Unmarshal(data, &cast)
+	return cast, err
+}
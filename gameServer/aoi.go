@@ -0,0 +1,140 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// Clients only receive entities within aoiRadius of their view center. An
+// entity already in view stays there until it is aoiHysteresis further out,
+// so things on the edge don't flicker in and out.
+var (
+	aoiRadius     = envFloat("AOI_RADIUS", 800)
+	aoiHysteresis = envFloat("AOI_HYSTERESIS", 100)
+)
+
+// interestSet holds the ids of the entities a client currently receives.
+type interestSet map[int]bool
+
+// update recomputes the set from center and returns what entered and left it.
+func (s interestSet) update(center Vec2D, grid *SpatialGrid, position func(int) (Vec2D, bool)) (entered, left []int) {
+	inView := make(map[int]bool)
+	query := Circle{X: center.X, Y: center.Y, Radius: aoiRadius + aoiHysteresis}
+	for _, id := range grid.QueryCircle(query) {
+		pos, ok := position(id)
+		if !ok {
+			continue
+		}
+		dist := math.Hypot(pos.X-center.X, pos.Y-center.Y)
+		if dist <= aoiRadius || (s[id] && dist <= aoiRadius+aoiHysteresis) {
+			inView[id] = true
+		}
+	}
+
+	for id := range s {
+		if !inView[id] {
+			delete(s, id)
+			left = append(left, id)
+		}
+	}
+	for id := range inView {
+		if !s[id] {
+			s[id] = true
+			entered = append(entered, id)
+		}
+	}
+	return entered, left
+}
+
+// viewCenter is the client's own player, or where it last was. Must be called
+// with mu held.
+func viewCenter(client *Client) Vec2D {
+	if state, exists := latestStates[client.Id]; exists {
+		client.view = Vec2D{X: state.PosX, Y: state.PosY}
+	}
+	return client.view
+}
+
+// watching reports whether the client gets world updates: it's in the lobby
+// or the match, or spectating. Clients in the menu or the editor don't.
+func watching(client *Client) bool {
+	return !client.dropped && (client.joined || client.spectator)
+}
+
+// dropClient closes the connection of a client an event couldn't be written
+// to. Its read loop then fails and removes it. Must be called with mu held.
+func dropClient(client *Client, msgType string, err error) {
+	log.Printf("Error sending %s to client %d, dropping it: %v", msgType, client.Id, err)
+	client.Conn.Close()
+	client.dropped = true
+}
+
+// inView reports whether pos is inside the client's area of interest. Must
+// be called with mu held.
+func inView(client *Client, pos Vec2D) bool {
+	center := viewCenter(client)
+	return math.Hypot(pos.X-center.X, pos.Y-center.Y) <= aoiRadius+aoiHysteresis
+}
+
+// sendInView sends an event that happened at pos to the clients that can see
+// it. Must be called with mu held.
+func sendInView(pos Vec2D, msg Message) {
+	for client := range clients {
+		if !watching(client) || !inView(client, pos) {
+			continue
+		}
+		if err := client.Conn.WriteJSON(msg); err != nil {
+			dropClient(client, msg.Type, err)
+		}
+	}
+}
+
+// Must be called with mu held.
+func playerPosition(id int) (Vec2D, bool) {
+	state, exists := latestStates[id]
+	return Vec2D{X: state.PosX, Y: state.PosY}, exists
+}
+
+// Must be called with pmu held.
+func projectilePosition(id int) (Vec2D, bool) {
+	proj, exists := projectiles[id]
+	return proj.Pos, exists
+}
+
+// visiblePlayers updates the client's players in view, tells it which ones
+// entered or left, and returns the states it should receive. Must be called
+// with mu held.
+func visiblePlayers(client *Client) (map[int]PlayerState, error) {
+	entered, left := client.playersInView.update(viewCenter(client), playerGrid, playerPosition)
+	for _, id := range entered {
+		if err := sendEntityEvent(client, "entity_enter", "player", id); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range left {
+		if err := sendEntityEvent(client, "entity_leave", "player", id); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	states := make(map[int]PlayerState, len(client.playersInView))
+	for id := range client.playersInView {
+		state := latestStates[id]
+		state.Statuses = activeStatuses(id, now)
+		states[id] = state
+	}
+	return states, nil
+}
+
+func sendEntityEvent(client *Client, eventType, kind string, id int) error {
+	log.Printf("Client %d: %s %s %d", client.Id, eventType, kind, id)
+	return client.Conn.WriteJSON(Message{
+		Type: eventType,
+		Content: map[string]interface{}{
+			"kind": kind,
+			"id":   id,
+		},
+	})
+}
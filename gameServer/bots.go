@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/rand"
+)
+
+type BotDifficulty struct {
+	AimError     float64       // maximum aim error in radians
+	ReactionTime time.Duration // delay before a bot attacks a new target
+	RetreatHP    float64       // fraction of max health below which the bot runs away, 0 never retreats
+}
+
+var botDifficulties = map[string]BotDifficulty{
+	"easy":   {AimError: 0.35, ReactionTime: 700 * time.Millisecond, RetreatHP: 0},
+	"normal": {AimError: 0.15, ReactionTime: 350 * time.Millisecond, RetreatHP: 0.25},
+	"hard":   {AimError: 0.05, ReactionTime: 150 * time.Millisecond, RetreatHP: 0.4},
+}
+
+var (
+	// Bots are added while there are fewer than minPlayers humans online,
+	// and removed again as humans join.
+	minPlayers       = envInt("MIN_PLAYERS", 4)
+	botDifficulty    = os.Getenv("BOT_DIFFICULTY")
+	botSightRange    = envFloat("BOT_SIGHT_RANGE", 500)
+	botRespawnDelay  = envDuration("BOT_RESPAWN_DELAY", 3*time.Second)
+	botMoveInterval  = time.Second / 20 // same rate clients send movement at
+	botWanderTimeout = 2 * time.Second
+)
+
+type Bot struct {
+	ID         int
+	Difficulty BotDifficulty
+
+	target      int
+	targetSince time.Time
+	lastMove    time.Time
+	moveBudget  time.Duration // time owed to movement, see move
+	wander      Vec2D
+	wanderUntil time.Time
+	diedAt      time.Time
+	seq         uint32
+	team        int // kept between respawns
+}
+
+var bots = make(map[int]*Bot) // guarded by mu
+
+var lastPlayerID int32
+
+// newPlayerID hands out ids shared by clients and bots.
+func newPlayerID() int {
+	return int(atomic.AddInt32(&lastPlayerID, 1))
+}
+
+func runBots() {
+	ticker := time.NewTicker(tickRate)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		mu.Lock()
+		navPlanner.Tick()
+		balanceBots()
+		for _, bot := range bots {
+			bot.think(now)
+		}
+		mu.Unlock()
+	}
+}
+
+// balanceBots keeps humans plus bots at minPlayers while anyone is playing.
+// Only humans in the match count, not ones in the menu, lobby or editor.
+// Must be called with mu held.
+func balanceBots() {
+	humans := 0
+	for client := range clients {
+		if client.playing {
+			humans++
+		}
+	}
+	switch {
+	case humans == 0 || humans >= minPlayers || gameMode == ModePvE || match.state != MatchPlaying:
+		for id := range bots {
+			removeBot(id)
+		}
+	case humans+len(bots) < minPlayers:
+		addBot()
+	case humans+len(bots) > minPlayers:
+		for id := range bots {
+			removeBot(id)
+			break
+		}
+	}
+}
+
+// Must be called with mu held.
+func addBot() {
+	difficulty, ok := botDifficulties[botDifficulty]
+	if !ok {
+		difficulty = botDifficulties["normal"]
+	}
+	bot := &Bot{
+		ID:         newPlayerID(),
+		Difficulty: difficulty,
+	}
+	bots[bot.ID] = bot
+	bot.spawn()
+	log.Println("Bot added:", bot.ID)
+}
+
+// Must be called with mu held.
+func removeBot(id int) {
+	delete(bots, id)
+	removePlayerState(id)
+	broadcast <- Message{
+		ClientID: id,
+		Type:     "player_left",
+	}
+	log.Println("Bot removed:", id)
+}
+
+// spawn places the bot with a random class. Must be called with mu held.
+func (b *Bot) spawn() {
+	classIDs := make([]int, 0, len(classMap))
+	for id := range classMap {
+		classIDs = append(classIDs, id)
+	}
+	heroClass := classIDs[rand.Intn(len(classIDs))]
+	pos := spawnPosition()
+	if b.team == 0 {
+		b.team = assignTeam()
+	}
+	setPlayerState(PlayerState{
+		ID:        b.ID,
+		PosX:      pos.X,
+		PosY:      pos.Y,
+		HeroClass: heroClass,
+		Nickname:  fmt.Sprintf("Bot %d", b.ID),
+		Health:    classMap[heroClass].Health,
+		Team:      b.team,
+	})
+	statsFor(b.ID, fmt.Sprintf("Bot %d", b.ID))
+	b.target = 0
+	b.diedAt = time.Time{}
+}
+
+// think runs one server tick of the bot. It only acts through applyMovement
+// and applyAttack, the same as a client would. Must be called with mu held.
+func (b *Bot) think(now time.Time) {
+	state, alive := latestStates[b.ID]
+	if !alive {
+		if b.diedAt.IsZero() {
+			b.diedAt = now
+		} else if now.Sub(b.diedAt) >= botRespawnDelay && gameMode != ModeBR {
+			b.spawn()
+		}
+		return
+	}
+
+	class := classMap[state.HeroClass]
+	pos := Vec2D{X: state.PosX, Y: state.PosY}
+	target, found := b.findTarget(pos)
+	if !found {
+		b.target = 0
+		b.move(now, pos, nextWaypoint(pos, b.wanderPoint(now, pos)), 1)
+		return
+	}
+	if target.ID != b.target {
+		b.target = target.ID
+		b.targetSince = now
+	}
+	targetPos := Vec2D{X: target.PosX, Y: target.PosY}
+	dist := math.Hypot(targetPos.X-pos.X, targetPos.Y-pos.Y)
+
+	switch {
+	case b.Difficulty.RetreatHP > 0 && state.Health < class.Health*b.Difficulty.RetreatHP:
+		b.move(now, pos, targetPos, -1)
+	case dist > class.AttackRange*0.8:
+		b.move(now, pos, nextWaypoint(pos, targetPos), 1)
+	default:
+		b.move(now, pos, targetPos, 0)
+	}
+
+	if now.Sub(b.targetSince) >= b.Difficulty.ReactionTime && dist <= class.AttackRange+playerRadius {
+		aim := b.aim(pos, targetPos)
+		applyAttack(PlayerAttack{
+			ID:         b.ID,
+			DirectionX: aim.X,
+			DirectionY: aim.Y,
+			ViewTick:   serverTick,
+		})
+	}
+}
+
+// findTarget returns the closest other player within sight.
+func (b *Bot) findTarget(pos Vec2D) (PlayerState, bool) {
+	var best PlayerState
+	bestDist := math.Inf(1)
+	for _, id := range playerGrid.QueryCircle(Circle{X: pos.X, Y: pos.Y, Radius: botSightRange}) {
+		if !canHitPlayer(b.ID, id) {
+			continue
+		}
+		other := latestStates[id]
+		dist := math.Hypot(other.PosX-pos.X, other.PosY-pos.Y)
+		if dist <= botSightRange && dist < bestDist {
+			best, bestDist = other, dist
+		}
+	}
+	return best, !math.IsInf(bestDist, 1)
+}
+
+func (b *Bot) wanderPoint(now time.Time, pos Vec2D) Vec2D {
+	if now.After(b.wanderUntil) || math.Hypot(b.wander.X-pos.X, b.wander.Y-pos.Y) < playerRadius {
+		b.wander = spawnPosition()
+		if gameMode == ModeBR {
+			b.wander = safeZonePoint()
+		}
+		b.wanderUntil = now.Add(botWanderTimeout)
+	}
+	return b.wander
+}
+
+// aim returns the point the bot shoots at, off the target by up to the
+// difficulty's aim error.
+func (b *Bot) aim(pos, targetPos Vec2D) Vec2D {
+	dx, dy := targetPos.X-pos.X, targetPos.Y-pos.Y
+	angle := math.Atan2(dy, dx) + (rand.Float64()*2-1)*b.Difficulty.AimError
+	dist := math.Hypot(dx, dy)
+	return Vec2D{X: pos.X + math.Cos(angle)*dist, Y: pos.Y + math.Sin(angle)*dist}
+}
+
+// move steps towards (sign 1), away from (sign -1) or just faces (sign 0)
+// the point, at the same rate clients send movement.
+func (b *Bot) move(now time.Time, pos, point Vec2D, sign int) {
+	// Bots think at the tick rate, which isn't a multiple of the move rate,
+	// so time carries over to keep the average the same as a client's
+	b.moveBudget += now.Sub(b.lastMove)
+	b.lastMove = now
+	if b.moveBudget > 2*botMoveInterval {
+		b.moveBudget = botMoveInterval // stood still for a while, no catching up
+	}
+	if b.moveBudget < botMoveInterval {
+		return
+	}
+	b.moveBudget -= botMoveInterval
+	b.seq++
+	applyMovement(PlayerMovement{
+		ID:         b.ID,
+		DirectionX: point.X,
+		DirectionY: point.Y,
+		MovingX:    sign * axisSign(point.X-pos.X),
+		MovingY:    sign * axisSign(point.Y-pos.Y),
+		Seq:        b.seq,
+	})
+}
+
+// axisSign turns a distance into a -1/0/1 movement input, with a dead zone so
+// bots don't jitter around a point.
+func axisSign(d float64) int {
+	switch {
+	case d > playerRadius/2:
+		return 1
+	case d < -playerRadius/2:
+		return -1
+	}
+	return 0
+}
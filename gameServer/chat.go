@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	ChatGlobal  = "global"
+	ChatTeam    = "team"
+	ChatWhisper = "whisper"
+)
+
+// Chat settings
+var (
+	chatMaxLength = envInt("CHAT_MAX_LENGTH", 200)
+	adminToken    = os.Getenv("ADMIN_TOKEN") // the admin endpoints are off when unset
+)
+
+type ChatSend struct {
+	Scope string `json:"scope"`
+	To    int    `json:"to,omitempty"` // client ID, for whispers
+	Text  string `json:"text"`
+}
+
+type ChatMessage struct {
+	From     int    `json:"from"` // 0 for messages from the server
+	Nickname string `json:"nickname"`
+	Scope    string `json:"scope"`
+	To       string `json:"to,omitempty"`   // whisper target's nickname
+	ToID     int    `json:"toId,omitempty"` // and client ID
+	Text     string `json:"text"`
+}
+
+// Muted player identities and when the mute runs out, guarded by mu, so a
+// mute sticks when the player reconnects. CHAT_MUTED takes a comma separated
+// list of identities, as logged by the mute endpoint, muted until restart.
+var mutes = make(map[string]time.Time)
+
+func init() {
+	for _, identity := range strings.Split(os.Getenv("CHAT_MUTED"), ",") {
+		if identity = strings.TrimSpace(identity); identity != "" {
+			mutes[identity] = time.Now().AddDate(100, 0, 0)
+		}
+	}
+}
+
+// Must be called with mu held.
+func isMuted(client *Client) bool {
+	now := time.Now()
+	if now.Before(client.mutedUntil) {
+		return true
+	}
+	identity := playerIdentity(client.profile)
+	until, ok := mutes[identity]
+	if ok && now.After(until) {
+		delete(mutes, identity)
+		return false
+	}
+	return ok
+}
+
+// cleanChatText drops control characters and cuts the text to the length
+// limit.
+func cleanChatText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+	text = strings.TrimSpace(text)
+	if runes := []rune(text); len(runes) > chatMaxLength {
+		text = string(runes[:chatMaxLength])
+	}
+	return text
+}
+
+func chatName(client *Client) string {
+	if client.profile.Nickname != "" {
+		return client.profile.Nickname
+	}
+	return fmt.Sprintf("Spectator %d", client.Id)
+}
+
+// handleChat delivers a chat_send to everyone in its scope. Must be called
+// with mu held.
+func handleChat(client *Client, content interface{}) error {
+	var send ChatSend
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &send); err != nil {
+		return err
+	}
+	text := cleanChatText(send.Text)
+	if text == "" {
+		return nil
+	}
+	nickname := chatName(client)
+	if isMuted(client) {
+		return sendSystemChat(client, "You are muted")
+	}
+
+	msg := ChatMessage{From: client.Id, Nickname: nickname, Scope: send.Scope, Text: text}
+	switch send.Scope {
+	case ChatGlobal:
+		broadcast <- Message{Type: "chat_message", Content: msg}
+	case ChatTeam:
+		// The team stays on the client while it's dead, so the dead can
+		// still talk to their team
+		team := client.team
+		if team == 0 {
+			return sendSystemChat(client, "You're not on a team")
+		}
+		for other := range clients {
+			if other.team == team && !other.dropped {
+				if err := other.Conn.WriteJSON(Message{Type: "chat_message", Content: msg}); err != nil {
+					dropClient(other, "chat_message", err)
+				}
+			}
+		}
+	case ChatWhisper:
+		target := clientByID(send.To)
+		if target == nil || !watching(target) {
+			return sendSystemChat(client, fmt.Sprintf("Player %d isn't online", send.To))
+		}
+		msg.To, msg.ToID = chatName(target), target.Id
+		if err := target.Conn.WriteJSON(Message{Type: "chat_message", Content: msg}); err != nil {
+			dropClient(target, "chat_message", err)
+		}
+		if target != client {
+			return client.Conn.WriteJSON(Message{Type: "chat_message", Content: msg})
+		}
+	default:
+		return fmt.Errorf("unknown chat scope %q", send.Scope)
+	}
+	log.Printf("Chat [%s] %s (%d): %s", send.Scope, nickname, client.Id, text)
+	return nil
+}
+
+func sendSystemChat(client *Client, text string) error {
+	return client.Conn.WriteJSON(Message{
+		Type:    "chat_message",
+		Content: ChatMessage{Nickname: "Server", Scope: ChatWhisper, Text: text},
+	})
+}
+
+// handleMute mutes a client for a while, e.g.
+// POST /admin/mute?id=12&duration=10m with the admin token in the
+// Authorization header. The ID is the one in the chat log. A zero duration
+// unmutes.
+func handleMute(w http.ResponseWriter, r *http.Request) {
+	if adminToken == "" || r.Header.Get("Authorization") != "Bearer "+adminToken {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, idErr := strconv.Atoi(r.URL.Query().Get("id"))
+	duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+	if idErr != nil || err != nil {
+		http.Error(w, "id and duration are required", http.StatusBadRequest)
+		return
+	}
+	mu.Lock()
+	client := clientByID(id)
+	if client == nil {
+		mu.Unlock()
+		http.Error(w, "no such client", http.StatusNotFound)
+		return
+	}
+	until := time.Now().Add(duration)
+	client.mutedUntil = until
+	nickname, identity := chatName(client), playerIdentity(client.profile)
+	if identity != "" && duration > 0 {
+		mutes[identity] = until
+	} else if identity != "" {
+		delete(mutes, identity)
+	}
+	mu.Unlock()
+	log.Printf("Muted %s (client %d, identity %q) for %v", nickname, id, identity, duration)
+	fmt.Fprintf(w, "muted %s for %v\n", nickname, duration)
+}
+
+// Must be called with mu held.
+func clientByID(id int) *Client {
+	for client := range clients {
+		if client.Id == id {
+			return client
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+type Ping struct {
+	ClientTime int64 `json:"clientTime"` // client clock, unix ms
+}
+
+type Pong struct {
+	ClientTime int64  `json:"clientTime"`
+	ServerTime int64  `json:"serverTime"` // server clock, unix ms
+	Tick       uint64 `json:"tick"`
+}
+
+// handlePing answers right away so the client can measure the round trip
+// and the offset between the two clocks.
+func handlePing(client *Client, msg Message) {
+	var ping Ping
+	data, err := json.Marshal(msg.Content)
+	if err != nil {
+		log.Printf("Error marshaling ping: %v", err)
+		return
+	}
+	if err := json.Unmarshal(data, &ping); err != nil {
+		log.Printf("Error unmarshaling ping: %v", err)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	pong := Message{
+		Type: "pong",
+		Tick: serverTick,
+		Content: Pong{
+			ClientTime: ping.ClientTime,
+			ServerTime: time.Now().UnixMilli(),
+			Tick:       serverTick,
+		},
+	}
+	if err := client.Conn.WriteJSON(pong); err != nil {
+		log.Printf("Error sending pong to client %d: %v", client.Id, err)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"log"
+
+	"golang.org/x/exp/rand"
+)
+
+// canHitPlayer reports whether attacks from ownerID damage targetID. Nobody
+// can be hurt outside of a running match.
+func canHitPlayer(ownerID, targetID int) bool {
+	if ownerID == targetID || match.state != MatchPlaying {
+		return false
+	}
+	if friendlyFire == FriendlyFireOff && sameTeam(ownerID, targetID) {
+		return false
+	}
+	// Players fight side by side against the monsters
+	return gameMode != ModePvE
+}
+
+// applyPlayerDamage saves a player whose health was reduced, or removes them
+// if it dropped to zero. killerID is 0 when no player dealt the damage, or
+// zoneKillerID for the battle royale zone. Returns true if the player died.
+// Must be called with mu held.
+func applyPlayerDamage(player PlayerState, killerID int) bool {
+	recordHit(killerID, player)
+	if player.Health <= 0 {
+		broadcast <- Message{
+			ClientID: player.ID,
+			Type:     "player_died",
+			Content:  map[string]interface{}{"killer": killerID},
+		}
+		if killerID > 0 {
+			scoreKill(killerID, player.ID)
+		}
+
+		removePlayerState(player.ID)
+		log.Printf("Player %d died", player.ID)
+		return true
+	}
+	setPlayerState(player) // Save updated state
+	return false
+}
+
+// Damage types
+const (
+	DamagePhysical = "physical" // reduced by PhysicalResistance
+	DamageMagic    = "magic"    // reduced by MagicResistance
+	DamageTrue     = "true"     // ignores resistances and shields, for the world
+)
+
+type DamageEvent struct {
+	SourceID int     `json:"source"`
+	TargetID int     `json:"target"`
+	Type     string  `json:"type"`
+	Amount   float64 `json:"amount"`   // health lost
+	Absorbed float64 `json:"absorbed"` // taken by a shield
+	Crit     bool    `json:"crit"`
+	Killed   bool    `json:"killed"`
+	PosX     float64 `json:"posX"`
+	PosY     float64 `json:"posY"`
+
+	// Set instead of the player IDs when a monster hits or is hit
+	SourceMonster int `json:"sourceMonster,omitempty"`
+	TargetMonster int `json:"targetMonster,omitempty"`
+}
+
+// dealDamage is the one way players get hurt. sourceID is a player, 0 for
+// none or zoneKillerID. Player sources roll crits and penetrate part of the
+// target's resistance, then shields soak up what they can. Returns true if
+// the target died. Must be called with mu held.
+func dealDamage(sourceID int, target PlayerState, amount float64, damageType string) bool {
+	return damagePlayer(DamageEvent{SourceID: sourceID}, target, amount, damageType)
+}
+
+// dealMonsterDamage is dealDamage for a monster's attack. Must be called with
+// mu held.
+func dealMonsterDamage(monsterID int, target PlayerState, amount float64, damageType string) bool {
+	return damagePlayer(DamageEvent{SourceMonster: monsterID}, target, amount, damageType)
+}
+
+// Must be called with mu held.
+func damagePlayer(event DamageEvent, target PlayerState, amount float64, damageType string) bool {
+	sourceID := event.SourceID
+	event.TargetID, event.Type, event.PosX, event.PosY = target.ID, damageType, target.PosX, target.PosY
+	amount, crit, penetration := outgoingDamage(sourceID, amount)
+	event.Crit = crit
+	amount *= damageScale(sourceID, target.ID)
+	if damageType != DamageTrue {
+		class := classMap[target.HeroClass]
+		amount = mitigate(amount, resistanceTo(damageType, class.PhysicalResistance, class.MagicResistance), penetration)
+		left := absorbDamage(target.ID, amount)
+		event.Absorbed = amount - left
+		amount = left
+	}
+	target.Health -= amount
+	event.Amount = amount
+	event.Killed = applyPlayerDamage(target, sourceID)
+	// The safe zone deals true damage every tick
+	if damageType != DamageTrue {
+		log.Printf("Player %d hit by %s from %d for %f damage", target.ID, damageType, sourceID, amount)
+	}
+	sendDamageEvent(event)
+	return event.Killed
+}
+
+// damageMonster puts a player's hit on a monster through the same crits,
+// resistances and damage events as hits on players. Monsters don't have
+// shields. Returns true if the monster died. Must be called with mu held.
+func damageMonster(sourceID int, m *Monster, amount float64, damageType string) bool {
+	event := DamageEvent{SourceID: sourceID, TargetMonster: m.ID, Type: damageType, PosX: m.PosX, PosY: m.PosY}
+	amount, crit, penetration := outgoingDamage(sourceID, amount)
+	event.Crit = crit
+	if damageType != DamageTrue {
+		class := monsterClasses[m.Class]
+		amount = mitigate(amount, resistanceTo(damageType, class.PhysicalResistance, class.MagicResistance), penetration)
+	}
+	m.Health -= amount
+	event.Amount = amount
+	if m.Health <= 0 {
+		removeMonster(m.ID)
+		broadcast <- Message{
+			Type:    "monster_died",
+			Content: map[string]interface{}{"id": m.ID, "killer": sourceID},
+		}
+		log.Printf("Monster %d killed by player %d", m.ID, sourceID)
+		event.Killed = true
+	}
+	sendDamageEvent(event)
+	return event.Killed
+}
+
+// outgoingDamage rolls the source player's crit and returns the damage with
+// the fraction of the target's resistance it ignores. Anything else hits for
+// the amount as is. Must be called with mu held.
+func outgoingDamage(sourceID int, amount float64) (damage float64, crit bool, penetration float64) {
+	source, exists := latestStates[sourceID]
+	if !exists || sourceID <= 0 {
+		return amount, false, 0
+	}
+	class := classMap[source.HeroClass]
+	damage, crit = rollCrit(class, amount)
+	return damage, crit, class.ArmorPenetration
+}
+
+// damageArea hits every player and monster in the area with the owner's
+// attack, checking players where they were at the given tick. Returns the
+// players that were hit and survived. Must be called with mu held.
+func damageArea(ownerID int, area Arc, tick uint64, damageType string) []int {
+	owner, exists := latestStates[ownerID]
+	if !exists {
+		return nil
+	}
+	amount := classMap[owner.HeroClass].Attack
+	var hit []int
+	for _, playerID := range queryPlayers(area.Circle) {
+		if !canHitPlayer(ownerID, playerID) {
+			continue
+		}
+		pos := positionAt(playerID, tick)
+		if !area.Intersects(Circle{X: pos.X, Y: pos.Y, Radius: playerRadius}) {
+			continue
+		}
+		if !dealDamage(ownerID, latestStates[playerID], amount, damageType) {
+			hit = append(hit, playerID)
+		}
+	}
+	damageMonsters(ownerID, area, amount, damageType)
+	return hit
+}
+
+func rollCrit(class PlayerClass, amount float64) (float64, bool) {
+	if rand.Float64() < class.CritChance {
+		return amount * class.CritMultiplier, true
+	}
+	return amount, false
+}
+
+func resistanceTo(damageType string, physical, magic float64) float64 {
+	switch damageType {
+	case DamagePhysical:
+		return physical
+	case DamageMagic:
+		return magic
+	}
+	return 0
+}
+
+// mitigate reduces damage by the resistance, of which penetration ignores a
+// fraction.
+func mitigate(amount, resistance, penetration float64) float64 {
+	return amount * (1 - resistance*(1-penetration))
+}
+
+// sendDamageEvent tells the source, the target and whoever can see the target
+// about the hit. Must be called with mu held.
+func sendDamageEvent(event DamageEvent) {
+	pos := Vec2D{X: event.PosX, Y: event.PosY}
+	for client := range clients {
+		involved := client.Id == event.SourceID || client.Id == event.TargetID
+		if client.dropped || !involved && (!watching(client) || !inView(client, pos)) {
+			continue
+		}
+		if err := client.Conn.WriteJSON(Message{Type: "damage", Content: event}); err != nil {
+			dropClient(client, "damage", err)
+		}
+	}
+}
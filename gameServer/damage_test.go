@@ -0,0 +1,156 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// withCrits sets every class's crit chance for the length of the test, so
+// damage doesn't depend on the dice.
+func withCrits(t *testing.T, chance float64) {
+	t.Helper()
+	old := classMap
+	t.Cleanup(func() { classMap = old })
+	classMap = make(map[int]PlayerClass, len(old))
+	for id, class := range old {
+		class.CritChance = chance
+		classMap[id] = class
+	}
+}
+
+func warrior(id int) PlayerState {
+	return PlayerState{ID: id, HeroClass: WarriorClass.ID, Health: WarriorClass.Health}
+}
+
+func TestMitigate(t *testing.T) {
+	tests := []struct {
+		name                            string
+		amount, resistance, penetration float64
+		want                            float64
+	}{
+		{name: "no resistance", amount: 40, want: 40},
+		{name: "half resisted", amount: 40, resistance: 0.5, want: 20},
+		{name: "penetration ignores part of it", amount: 40, resistance: 0.5, penetration: 0.5, want: 30},
+		{name: "full penetration", amount: 40, resistance: 0.5, penetration: 1, want: 40},
+		{name: "immune", amount: 40, resistance: 1, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mitigate(tt.amount, tt.resistance, tt.penetration); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDealDamage(t *testing.T) {
+	shield := StatusApply{Kind: StatusShield, Magnitude: 10, Duration: time.Second}
+	tests := []struct {
+		name       string
+		source     PlayerState // ID 0 for no player
+		target     PlayerState
+		shield     bool
+		amount     float64
+		damageType string
+		critChance float64
+		health     float64 // left after the hit
+		absorbed   float64
+		killed     bool
+	}{
+		{
+			name:   "physical against no resistance",
+			source: warrior(1), target: mage(2), amount: 25, damageType: DamagePhysical,
+			health: MageClass.Health - 25,
+		},
+		{
+			name:   "physical against armor, partly penetrated",
+			source: mage(1), target: warrior(2), amount: 30, damageType: DamagePhysical,
+			health: WarriorClass.Health - 30*(1-WarriorClass.PhysicalResistance*(1-MageClass.ArmorPenetration)),
+		},
+		{
+			name:   "magic against magic resistance",
+			source: warrior(1), target: mage(2), amount: 20, damageType: DamageMagic,
+			health: MageClass.Health - 20*(1-MageClass.MagicResistance*(1-WarriorClass.ArmorPenetration)),
+		},
+		{
+			name:   "no source, no penetration",
+			target: warrior(2), amount: 20, damageType: DamagePhysical,
+			health: WarriorClass.Health - 20*(1-WarriorClass.PhysicalResistance),
+		},
+		{
+			name:   "shield soaks up damage first",
+			source: warrior(1), target: mage(2), shield: true, amount: 25, damageType: DamagePhysical,
+			health: MageClass.Health - 15, absorbed: 10,
+		},
+		{
+			name:   "true damage ignores resistance and shields",
+			target: warrior(2), shield: true, amount: 20, damageType: DamageTrue,
+			health: WarriorClass.Health - 20,
+		},
+		{
+			name:   "crit",
+			source: warrior(1), target: mage(2), amount: 20, damageType: DamagePhysical, critChance: 1,
+			health: MageClass.Health - 20*WarriorClass.CritMultiplier,
+		},
+		{
+			name:   "killing blow",
+			source: warrior(1), target: mage(2), amount: 500, damageType: DamagePhysical,
+			killed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPlayers(t, tt.target)
+			if tt.source.ID != 0 {
+				setPlayerState(tt.source)
+			}
+			withCrits(t, tt.critChance)
+			if tt.shield {
+				applyStatus(tt.target.ID, tt.target.ID, shield)
+			}
+
+			killed := dealDamage(tt.source.ID, tt.target, tt.amount, tt.damageType)
+			if killed != tt.killed {
+				t.Fatalf("killed %v, want %v", killed, tt.killed)
+			}
+			state, alive := latestStates[tt.target.ID]
+			if alive == tt.killed {
+				t.Fatalf("target in the world %v after a killing blow %v", alive, tt.killed)
+			}
+			if alive && math.Abs(state.Health-tt.health) > 1e-9 {
+				t.Errorf("health %v, want %v", state.Health, tt.health)
+			}
+			if tt.shield && tt.damageType != DamageTrue {
+				if left := statusOf(tt.target.ID, StatusShield).Magnitude; left != shield.Magnitude-tt.absorbed {
+					t.Errorf("shield has %v left, want %v", left, shield.Magnitude-tt.absorbed)
+				}
+			}
+		})
+	}
+}
+
+func TestMonsterDamage(t *testing.T) {
+	withPlayers(t, mage(1), warrior(2))
+	withCrits(t, 0)
+
+	// Monsters hit players through the same resistances, without penetration
+	dealMonsterDamage(7, latestStates[2], 20, DamagePhysical)
+	if want := WarriorClass.Health - 20*(1-WarriorClass.PhysicalResistance); latestStates[2].Health != want {
+		t.Errorf("warrior health %v, want %v", latestStates[2].Health, want)
+	}
+
+	// And players hit monsters through theirs
+	zombie := monsterClasses[1]
+	m := &Monster{ID: 7, Class: zombie.ID, Health: zombie.Health}
+	if damageMonster(1, m, 30, DamagePhysical) {
+		t.Fatal("zombie died from one hit")
+	}
+	want := zombie.Health - 30*(1-zombie.PhysicalResistance*(1-MageClass.ArmorPenetration))
+	if math.Abs(m.Health-want) > 1e-9 {
+		t.Errorf("zombie health %v, want %v", m.Health, want)
+	}
+	if !damageMonster(1, m, 500, DamageTrue) {
+		t.Error("zombie survived true damage past its health")
+	}
+}
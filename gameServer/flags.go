@@ -0,0 +1,191 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// Capture the flag settings
+var (
+	ctfCaptureLimit = envInt("CTF_CAPTURE_LIMIT", 3)
+	flagReturnTime  = envDuration("FLAG_RETURN_TIME", 20*time.Second)
+)
+
+const flagRadius = 20 // touch distance for picking up and capturing
+
+// Flag belongs to a team and sits at its base until an enemy takes it.
+type Flag struct {
+	Team    int   `json:"team"`
+	Base    Vec2D `json:"base"`
+	Pos     Vec2D `json:"pos"`
+	Carrier int   `json:"carrier"` // player ID, 0 when not carried
+	AtBase  bool  `json:"atBase"`
+	Hidden  bool  `json:"hidden,omitempty"` // away from its base and out of view
+
+	droppedAt time.Time
+}
+
+var flags []*Flag // guarded by mu
+
+// initFlags puts one flag per team on the map's objectives, or spreads them
+// around the arena if the map doesn't have enough.
+func initFlags() {
+	width, height := arena.WorldSize()
+	margin := 3 * arena.TileSize
+	for team := 1; team <= teamCount; team++ {
+		var base Vec2D
+		if team <= len(arena.Objectives) {
+			base = arena.Objectives[team-1]
+		} else {
+			angle := math.Pi + 2*math.Pi*float64(team-1)/float64(teamCount)
+			base = Vec2D{
+				X: width/2 + (width/2-margin)*math.Cos(angle),
+				Y: height/2 + (height/2-margin)*math.Sin(angle),
+			}
+			if arena.CircleBlocked(base, flagRadius) {
+				base = spawnPosition()
+			}
+		}
+		flags = append(flags, &Flag{Team: team, Base: base, Pos: base, AtBase: true})
+		log.Printf("Team %d flag at %.0f,%.0f", team, base.X, base.Y)
+	}
+}
+
+func runCTF() {
+	ticker := time.NewTicker(tickRate)
+	defer ticker.Stop()
+
+	sender := modeStateSender[[]Flag]{
+		every:    modeStateEvery,
+		snapshot: copyFlags,
+		changed:  flagsChanged,
+		send:     func(time.Time) { sendFlags() },
+	}
+	for now := range ticker.C {
+		mu.Lock()
+		if match.state == MatchPlaying {
+			for _, flag := range flags {
+				flag.update(now)
+			}
+		}
+		sender.update(now)
+		mu.Unlock()
+	}
+}
+
+// Must be called with mu held.
+func copyFlags() []Flag {
+	copied := make([]Flag, len(flags))
+	for i, flag := range flags {
+		copied[i] = *flag
+	}
+	return copied
+}
+
+// flagsChanged reports whether any flag moved or changed hands.
+func flagsChanged(sent, current []Flag) bool {
+	if len(sent) != len(current) {
+		return true
+	}
+	for i := range current {
+		if current[i] != sent[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Must be called with mu held.
+func sendFlags() {
+	for client := range clients {
+		if !inMatch(client) {
+			continue
+		}
+		msg := Message{Type: "flags_update", Content: flagsFor(client)}
+		if err := client.Conn.WriteJSON(msg); err != nil {
+			dropClient(client, msg.Type, err)
+		}
+	}
+}
+
+// flagsFor copies the flags for one client. Bases are always shown, but a
+// dropped or carried flag outside the client's area of interest only shows
+// that it's gone. Must be called with mu held.
+func flagsFor(client *Client) []Flag {
+	view := make([]Flag, len(flags))
+	for i, flag := range flags {
+		view[i] = *flag
+		if !flag.AtBase && !inView(client, flag.Pos) {
+			view[i].Pos = Vec2D{}
+			view[i].Hidden = true
+		}
+	}
+	return view
+}
+
+// update moves a carried flag with its carrier and handles pickups, drops,
+// returns and captures. Must be called with mu held.
+func (f *Flag) update(now time.Time) {
+	if f.Carrier != 0 {
+		carrier, alive := latestStates[f.Carrier]
+		if !alive {
+			// Carrier died or left, the flag stays where they were last seen
+			f.event("flag_dropped", f.Carrier)
+			f.Carrier = 0
+			f.droppedAt = now
+			return
+		}
+		f.Pos = Vec2D{X: carrier.PosX, Y: carrier.PosY}
+		own := flags[carrier.Team-1]
+		if own.AtBase && touching(f.Pos, own.Base) {
+			log.Printf("Player %d captured the team %d flag", f.Carrier, f.Team)
+			f.event("flag_captured", f.Carrier)
+			f.reset()
+			addTeamScore(carrier.Team)
+		}
+		return
+	}
+
+	if !f.AtBase && now.Sub(f.droppedAt) > flagReturnTime {
+		f.event("flag_returned", 0)
+		f.reset()
+		return
+	}
+
+	for _, id := range playerGrid.QueryCircle(Circle{X: f.Pos.X, Y: f.Pos.Y, Radius: flagRadius + playerRadius}) {
+		player := latestStates[id]
+		if !touching(f.Pos, Vec2D{X: player.PosX, Y: player.PosY}) || player.Team == 0 {
+			continue
+		}
+		if player.Team != f.Team {
+			f.Carrier = id
+			f.AtBase = false
+			f.event("flag_taken", id)
+			return
+		}
+		// Touching your own dropped flag sends it home
+		if !f.AtBase {
+			f.event("flag_returned", id)
+			f.reset()
+			return
+		}
+	}
+}
+
+func (f *Flag) reset() {
+	f.Pos = f.Base
+	f.Carrier = 0
+	f.AtBase = true
+}
+
+func (f *Flag) event(eventType string, playerID int) {
+	broadcast <- Message{
+		Type:    eventType,
+		Content: map[string]interface{}{"team": f.Team, "player": playerID},
+	}
+}
+
+func touching(a, b Vec2D) bool {
+	return math.Hypot(a.X-b.X, a.Y-b.Y) <= flagRadius+playerRadius
+}
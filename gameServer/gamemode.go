@@ -0,0 +1,61 @@
+package main
+
+import "time"
+
+const (
+	ModeFFA  = "ffa"  // free for all, the default
+	ModePvE  = "pve"  // players together against waves of monsters
+	ModeTDM  = "tdm"  // team deathmatch
+	ModeCTF  = "ctf"  // capture the flag
+	ModeKOTH = "koth" // king of the hill
+	ModeBR   = "br"   // battle royale, last player standing
+)
+
+var gameMode = envString("GAME_MODE", ModeFFA)
+
+// Game mode state is sent when it changes, and at least this often so anyone
+// who just spawned catches up
+var modeStateEvery = envDuration("MODE_STATE_EVERY", time.Second)
+
+// modeStateSender sends a game mode's state when it changes, and at least
+// every `every` so anyone who just spawned catches up. snapshot copies the
+// state and changed compares it with the copy last sent. Only used from the
+// mode's own loop, with mu held.
+type modeStateSender[T any] struct {
+	every    time.Duration
+	snapshot func() T
+	changed  func(sent, current T) bool
+	send     func(now time.Time)
+
+	sent   T
+	sentAt time.Time
+}
+
+// update sends the state if it changed or it's been a while.
+func (s *modeStateSender[T]) update(now time.Time) {
+	current := s.snapshot()
+	if !s.changed(s.sent, current) && now.Sub(s.sentAt) < s.every {
+		return
+	}
+	s.sent, s.sentAt = current, now
+	s.send(now)
+}
+
+// inMatch reports whether the client needs the game mode's state: it's
+// spawned into the match or spectating it.
+func inMatch(client *Client) bool {
+	return !client.dropped && (client.playing || client.spectator)
+}
+
+// sendToMatch sends the message to every client in the match. Must be called
+// with mu held.
+func sendToMatch(msg Message) {
+	for client := range clients {
+		if !inMatch(client) {
+			continue
+		}
+		if err := client.Conn.WriteJSON(msg); err != nil {
+			dropClient(client, msg.Type, err)
+		}
+	}
+}
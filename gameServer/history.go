@@ -0,0 +1,60 @@
+package main
+
+import "time"
+
+// historySize is the number of ticks kept for lag compensation, a little
+// over two seconds at 30 Hz.
+const historySize = 64
+
+// maxRewind caps how far back an attack may be rewound, so very high ping
+// players can't hit targets that have long since moved away.
+var maxRewind = envDuration("MAX_REWIND", 200*time.Millisecond)
+
+type historyFrame struct {
+	tick      uint64
+	positions map[int]Vec2D
+}
+
+var (
+	serverTick uint64
+	history    [historySize]historyFrame
+)
+
+// recordHistory stores the positions of all players for the current tick.
+// Must be called with mu held.
+func recordHistory() {
+	positions := make(map[int]Vec2D, len(latestStates))
+	for id, state := range latestStates {
+		positions[id] = Vec2D{X: state.PosX, Y: state.PosY}
+	}
+	history[serverTick%historySize] = historyFrame{
+		tick:      serverTick,
+		positions: positions,
+	}
+}
+
+// rewindTick clamps the tick a client was looking at to the allowed rewind
+// window. Must be called with mu held.
+func rewindTick(viewTick uint64) uint64 {
+	maxTicks := uint64(maxRewind / tickRate)
+	if viewTick == 0 || viewTick > serverTick {
+		return serverTick
+	}
+	if serverTick-viewTick > maxTicks {
+		return serverTick - maxTicks
+	}
+	return viewTick
+}
+
+// positionAt returns where a player was at the given tick, or their current
+// position if the tick is no longer in the history. Must be called with mu held.
+func positionAt(playerID int, tick uint64) Vec2D {
+	frame := history[tick%historySize]
+	if frame.tick == tick {
+		if pos, ok := frame.positions[playerID]; ok {
+			return pos
+		}
+	}
+	state := latestStates[playerID]
+	return Vec2D{X: state.PosX, Y: state.PosY}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	MatchLobby      = "lobby"       // waiting for enough players
+	MatchReadyCheck = "ready_check" // everyone in the lobby has to ready up
+	MatchCountdown  = "countdown"
+	MatchPlaying    = "playing"
+	MatchResults    = "results"
+)
+
+// Match settings
+var (
+	matchMinPlayers   = envInt("MATCH_MIN_PLAYERS", 2)
+	matchReadyTimeout = envDuration("MATCH_READY_TIMEOUT", 20*time.Second)
+	matchCountdown    = envDuration("MATCH_COUNTDOWN", 5*time.Second)
+	matchDuration     = envDuration("MATCH_DURATION", 5*time.Minute)
+	matchResultsTime  = envDuration("MATCH_RESULTS_TIME", 10*time.Second)
+)
+
+type MatchPlayer struct {
+	ID        int     `json:"id"`
+	Nickname  string  `json:"nickname"`
+	HeroClass int     `json:"heroClass"`
+	Ready     bool    `json:"ready"`
+	Rating    float64 `json:"rating"`
+}
+
+type PlayerStats struct {
+	ID       int     `json:"id"`
+	Nickname string  `json:"nickname"`
+	Kills    int     `json:"kills"`
+	Deaths   int     `json:"deaths"`
+	Damage   float64 `json:"damage"`
+}
+
+// MatchInfo is the content of every match_state message.
+type MatchInfo struct {
+	State      string         `json:"state"`
+	Mode       string         `json:"mode"`
+	Remaining  float64        `json:"remaining"` // seconds left in timed states
+	MinPlayers int            `json:"minPlayers"`
+	Players    []MatchPlayer  `json:"players"`
+	CanJoin    bool           `json:"canJoin"`           // players can spawn in right now
+	Summary    string         `json:"summary,omitempty"` // how the match ended
+	Results    []*PlayerStats `json:"results,omitempty"`
+}
+
+// Guarded by mu
+var match = struct {
+	state      string
+	endsAt     time.Time
+	summary    string
+	stats      map[int]*PlayerStats
+	results    []*PlayerStats
+	lastNotify time.Time
+}{state: MatchLobby, stats: make(map[int]*PlayerStats)}
+
+func runMatch() {
+	ticker := time.NewTicker(time.Second / 10)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		mu.Lock()
+		updateMatch(now)
+		// Keep lobby lists and timers fresh without a message per change
+		if now.Sub(match.lastNotify) >= time.Second {
+			broadcastMatchState()
+		}
+		mu.Unlock()
+	}
+}
+
+// updateMatch moves the match along once its conditions are met. Must be
+// called with mu held.
+func updateMatch(now time.Time) {
+	players := lobbyPlayers()
+	ready := 0
+	for _, p := range players {
+		if p.Ready {
+			ready++
+		}
+	}
+
+	switch match.state {
+	case MatchLobby:
+		if len(players) >= matchMinPlayers {
+			setMatchState(MatchReadyCheck, now.Add(matchReadyTimeout))
+		}
+	case MatchReadyCheck:
+		switch {
+		case len(players) < matchMinPlayers:
+			setMatchState(MatchLobby, time.Time{})
+		case ready == len(players):
+			setMatchState(MatchCountdown, now.Add(matchCountdown))
+		case now.After(match.endsAt) && ready >= matchMinPlayers:
+			// Go ahead without the players who didn't ready up
+			setMatchState(MatchCountdown, now.Add(matchCountdown))
+		case now.After(match.endsAt):
+			log.Printf("Ready check failed with %d/%d ready", ready, len(players))
+			setMatchState(MatchLobby, time.Time{})
+		}
+	case MatchCountdown:
+		if ready < matchMinPlayers {
+			setMatchState(MatchLobby, time.Time{})
+		} else if now.After(match.endsAt) {
+			// Keeps counting at zero until the skill windows are wide enough
+			if room := formRoom(now); len(room) >= matchMinPlayers {
+				startMatch(now, room)
+			}
+		}
+	case MatchPlaying:
+		if len(players) == 0 {
+			endMatch("")
+		} else if now.After(match.endsAt) {
+			endMatch("Time's up")
+		} else if canJoinMatch() {
+			for _, client := range lateJoiners(now) {
+				if err := spawnPlayer(client); err != nil {
+					log.Printf("Error spawning player %d: %v", client.Id, err)
+				}
+			}
+		}
+	case MatchResults:
+		if now.After(match.endsAt) {
+			returnToLobby()
+		}
+	}
+}
+
+// Must be called with mu held.
+func setMatchState(state string, endsAt time.Time) {
+	match.state = state
+	match.endsAt = endsAt
+	log.Println("Match state:", state)
+	broadcastMatchState()
+}
+
+// Must be called with mu held.
+func broadcastMatchState() {
+	match.lastNotify = time.Now()
+	broadcast <- Message{Type: "match_state", Content: matchInfo()}
+}
+
+// Must be called with mu held.
+func matchInfo() MatchInfo {
+	info := MatchInfo{
+		State:      match.state,
+		Mode:       gameMode,
+		MinPlayers: matchMinPlayers,
+		Players:    lobbyPlayers(),
+		CanJoin:    canJoinMatch(),
+	}
+	if !match.endsAt.IsZero() {
+		info.Remaining = math.Max(0, time.Until(match.endsAt).Seconds())
+	}
+	if match.state == MatchResults {
+		info.Summary = match.summary
+		info.Results = match.results
+	}
+	return info
+}
+
+// lobbyPlayers lists the clients that have picked a class and nickname. Must
+// be called with mu held.
+func lobbyPlayers() []MatchPlayer {
+	var players []MatchPlayer
+	for client := range clients {
+		if client.joined {
+			players = append(players, MatchPlayer{
+				ID:        client.Id,
+				Nickname:  client.profile.Nickname,
+				HeroClass: client.profile.HeroClass,
+				Ready:     client.ready,
+				Rating:    ratingOf(client.profile).Rating,
+			})
+		}
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].ID < players[j].ID })
+	return players
+}
+
+// canJoinMatch reports whether queued players can be added to the running
+// match. They can't in battle royale, where it's last player standing. Must be
+// called with mu held.
+func canJoinMatch() bool {
+	return match.state == MatchPlaying && gameMode != ModeBR
+}
+
+// startMatch resets the game mode and spawns the players matchmaking picked.
+// Must be called with mu held.
+func startMatch(now time.Time, room []*Client) {
+	resetModeState(now)
+	match.stats = make(map[int]*PlayerStats)
+	match.summary = ""
+	match.results = nil
+	setMatchState(MatchPlaying, now.Add(matchDuration))
+	for _, client := range room {
+		if err := spawnPlayer(client); err != nil {
+			log.Printf("Error spawning player %d: %v", client.Id, err)
+		}
+	}
+}
+
+// endMatch stops play and shows everyone the results. Game modes call it when
+// someone wins, with a summary like "Team 2 wins". Must be called with mu
+// held.
+func endMatch(summary string) {
+	if match.state != MatchPlaying {
+		return
+	}
+	match.summary = summary
+	match.results = make([]*PlayerStats, 0, len(match.stats))
+	for _, stats := range match.stats {
+		match.results = append(match.results, stats)
+	}
+	sort.Slice(match.results, func(i, j int) bool {
+		a, b := match.results[i], match.results[j]
+		if a.Kills != b.Kills {
+			return a.Kills > b.Kills
+		}
+		return a.Deaths < b.Deaths
+	})
+	updateRatings(match.results)
+	setMatchState(MatchResults, time.Now().Add(matchResultsTime))
+}
+
+// returnToLobby clears the world for the next match. Must be called with mu
+// held.
+func returnToLobby() {
+	for id := range bots {
+		removeBot(id)
+	}
+	for id := range latestStates {
+		removePlayerState(id)
+		broadcast <- Message{ClientID: id, Type: "player_left"}
+	}
+	// Players who sat this one out keep their place in the queue
+	for client := range clients {
+		if client.playing {
+			client.ready = false
+			client.playing = false
+			client.team = 0
+		}
+	}
+	setMatchState(MatchLobby, time.Time{})
+}
+
+// resetModeState starts the game mode's scoring from scratch. Must be called
+// with mu held.
+func resetModeState(now time.Time) {
+	switch gameMode {
+	case ModeTDM, ModeCTF:
+		teamScores = make([]int, teamCount)
+		for _, flag := range flags {
+			flag.reset()
+		}
+		broadcastTeamScores()
+	case ModeKOTH:
+		kothScores = make(map[int]float64)
+		rotateZones(now)
+	case ModeBR:
+		resetSafeZone(now)
+	case ModePvE:
+		for id := range monsters {
+			removeMonster(id)
+		}
+		wave = WaveState{}
+	}
+}
+
+// recordHit updates the match stats for damage done to player, before their
+// new state is saved. attackerID is 0 or negative for damage from the world.
+// Must be called with mu held.
+func recordHit(attackerID int, player PlayerState) {
+	prev, ok := latestStates[player.ID]
+	if !ok || match.state != MatchPlaying {
+		return
+	}
+	victim := statsFor(player.ID, player.Nickname)
+	if player.Health <= 0 {
+		victim.Deaths++
+	}
+	if attackerID <= 0 || attackerID == player.ID {
+		return
+	}
+	attacker := statsFor(attackerID, latestStates[attackerID].Nickname)
+	attacker.Damage += math.Max(0, prev.Health-math.Max(0, player.Health))
+	if player.Health <= 0 {
+		attacker.Kills++
+	}
+}
+
+func statsFor(id int, nickname string) *PlayerStats {
+	stats, ok := match.stats[id]
+	if !ok {
+		stats = &PlayerStats{ID: id}
+		match.stats[id] = stats
+	}
+	if nickname != "" {
+		stats.Nickname = nickname
+	}
+	return stats
+}
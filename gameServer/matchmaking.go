@@ -0,0 +1,326 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Matchmaking settings. Without accounts a player is known by the key their
+// client generates and keeps, and they have a separate rating for each class.
+var (
+	ratingsFile       = os.Getenv("RATINGS_FILE") // ratings are kept in memory only when unset
+	initialRating     = envFloat("RATING_INITIAL", 1000)
+	ratingK           = envFloat("RATING_K", 32)
+	matchMaxPlayers   = envInt("MATCH_MAX_PLAYERS", 8)
+	skillWindow       = envFloat("SKILL_WINDOW", 100)       // rating difference allowed straight away
+	skillWindowGrowth = envFloat("SKILL_WINDOW_GROWTH", 10) // extra rating difference per second queued
+)
+
+type Rating struct {
+	Rating float64 `json:"rating"`
+	Games  int     `json:"games"`
+}
+
+var ratings = make(map[string]*Rating) // by ratingKey, guarded by mu
+
+// Player keys shorter than this are too easy to guess, and longer ones are
+// just wasted memory
+const (
+	minPlayerKey = 16
+	maxPlayerKey = 128
+)
+
+// playerIdentity stays the same for a player across sessions. It's a hash of
+// their key, so it can be logged and stored without giving the key away.
+// Returns "" for clients without a usable key.
+func playerIdentity(profile PlayerData) string {
+	if len(profile.Key) < minPlayerKey || len(profile.Key) > maxPlayerKey {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(profile.Key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ratingKey identifies the player and class in the ratings. Returns "" for
+// clients without a usable key, which aren't rated.
+func ratingKey(profile PlayerData) string {
+	identity := playerIdentity(profile)
+	if identity == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%d", identity, profile.HeroClass)
+}
+
+// ratingOf looks up the player's rating without adding them. Must be called
+// with mu held.
+func ratingOf(profile PlayerData) Rating {
+	if r, ok := ratings[ratingKey(profile)]; ok {
+		return *r
+	}
+	return Rating{Rating: initialRating}
+}
+
+// ratingFor returns the player's rating to update, adding them if they're
+// new. Must be called with mu held.
+func ratingFor(profile PlayerData) *Rating {
+	key := ratingKey(profile)
+	r, ok := ratings[key]
+	if !ok {
+		r = &Rating{Rating: initialRating}
+		ratings[key] = r
+	}
+	return r
+}
+
+func loadRatings() {
+	if ratingsFile == "" {
+		return
+	}
+	data, err := os.ReadFile(ratingsFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &ratings)
+	}
+	if err != nil {
+		log.Printf("Error loading ratings: %v", err)
+		return
+	}
+	log.Printf("Loaded %d ratings", len(ratings))
+}
+
+// Saves are written in the background, newest last
+var (
+	ratingsSaved   uint64 // guarded by mu
+	ratingsFileMu  sync.Mutex
+	ratingsWritten uint64 // guarded by ratingsFileMu
+)
+
+// saveRatings copies the ratings and writes them to the file without holding
+// up the game. Must be called with mu held.
+func saveRatings() {
+	if ratingsFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(ratings, "", "  ")
+	if err != nil {
+		log.Printf("Error saving ratings: %v", err)
+		return
+	}
+	ratingsSaved++
+	go writeRatings(ratingsSaved, data)
+}
+
+func writeRatings(save uint64, data []byte) {
+	ratingsFileMu.Lock()
+	defer ratingsFileMu.Unlock()
+	if save < ratingsWritten {
+		return // a newer save got here first
+	}
+	ratingsWritten = save
+	if err := os.WriteFile(ratingsFile, data, 0o644); err != nil {
+		log.Printf("Error saving ratings: %v", err)
+	}
+}
+
+// skillWindowFor is how far from their rating a player will accept
+// opponents, growing the longer they've been queued.
+func skillWindowFor(client *Client, now time.Time) float64 {
+	return skillWindow + skillWindowGrowth*now.Sub(client.queuedAt).Seconds()
+}
+
+// queuedClients lists the players readied up in the lobby and not already
+// playing, longest waiting first. Must be called with mu held.
+func queuedClients() []*Client {
+	var queue []*Client
+	for client := range clients {
+		if client.joined && client.ready && !client.playing {
+			queue = append(queue, client)
+		}
+	}
+	sort.Slice(queue, func(i, j int) bool { return queue[i].queuedAt.Before(queue[j].queuedAt) })
+	return queue
+}
+
+// formRoom picks the players for the next match: the longest waiting player
+// and whoever else in the queue is within both their skill windows, up to
+// matchMaxPlayers. Must be called with mu held.
+func formRoom(now time.Time) []*Client {
+	queue := queuedClients()
+	if len(queue) == 0 {
+		return nil
+	}
+	anchor := queue[0]
+	anchorRating := ratingOf(anchor.profile).Rating
+	room := []*Client{anchor}
+	for _, client := range queue[1:] {
+		if len(room) >= matchMaxPlayers {
+			break
+		}
+		diff := math.Abs(ratingOf(client.profile).Rating - anchorRating)
+		if diff <= skillWindowFor(anchor, now) && diff <= skillWindowFor(client, now) {
+			room = append(room, client)
+		}
+	}
+	return room
+}
+
+// lateJoiners picks queued players to add to the running match, as long as
+// they're within their skill window of the match's average rating, up to
+// matchMaxPlayers. Must be called with mu held.
+func lateJoiners(now time.Time) []*Client {
+	playing, total := 0, 0.0
+	for client := range clients {
+		if client.playing {
+			playing++
+			total += ratingOf(client.profile).Rating
+		}
+	}
+	if playing == 0 {
+		return nil
+	}
+	average := total / float64(playing)
+	var joiners []*Client
+	for _, client := range queuedClients() {
+		if playing+len(joiners) >= matchMaxPlayers {
+			break
+		}
+		if math.Abs(ratingOf(client.profile).Rating-average) <= skillWindowFor(client, now) {
+			joiners = append(joiners, client)
+		}
+	}
+	return joiners
+}
+
+// updateRatings runs a round of Elo between every pair of opponents in the
+// match. Free for all modes rank players by the results, team modes by how
+// their teams finished. Bots and clients without a key aren't rated. Must be
+// called with mu held, before the mode's scores are reset.
+func updateRatings(results []*PlayerStats) {
+	profiles := make(map[int]PlayerData)
+	teams := make(map[int]int)
+	for client := range clients {
+		if client.playing && ratingKey(client.profile) != "" {
+			profiles[client.Id] = client.profile
+			teams[client.Id] = client.team
+		}
+	}
+	var rated []*PlayerStats
+	for _, stats := range results {
+		if _, ok := profiles[stats.ID]; ok {
+			rated = append(rated, stats)
+		}
+	}
+	if len(rated) < 2 {
+		return
+	}
+	var standings map[int]float64
+	if teamMode() {
+		standings = teamStandings()
+	}
+
+	// Work out every change from the ratings before the match
+	before := make([]float64, len(rated))
+	for i, stats := range rated {
+		before[i] = ratingOf(profiles[stats.ID]).Rating
+	}
+	for i, a := range rated {
+		change := 0.0
+		opponents := 0
+		for j, b := range rated {
+			if i == j {
+				continue
+			}
+			score, opposed := matchScore(a, b, teams, standings)
+			if !opposed {
+				continue
+			}
+			expected := 1 / (1 + math.Pow(10, (before[j]-before[i])/400))
+			change += score - expected
+			opponents++
+		}
+		if opponents == 0 {
+			continue
+		}
+		change *= ratingK / float64(opponents)
+		r := ratingFor(profiles[a.ID])
+		r.Rating += change
+		r.Games++
+		log.Printf("Rating for %s: %.0f (%+.0f)", ratingKey(profiles[a.ID]), r.Rating, change)
+	}
+	saveRatings()
+}
+
+// matchScore is how a did against b: 1 for a win, 0.5 for a draw and 0 for a
+// loss. With team standings it's down to how their teams finished, and
+// teammates aren't opponents.
+func matchScore(a, b *PlayerStats, teams map[int]int, standings map[int]float64) (score float64, opposed bool) {
+	if standings == nil {
+		return placementScore(a, b), true
+	}
+	teamA, teamB := teams[a.ID], teams[b.ID]
+	switch {
+	case teamA == teamB:
+		return 0, false
+	case standings[teamA] != standings[teamB]:
+		return boolScore(standings[teamA] > standings[teamB]), true
+	default:
+		return 0.5, true
+	}
+}
+
+// placementScore is 1 if a did better than b, 0.5 for a draw and 0 if worse.
+func placementScore(a, b *PlayerStats) float64 {
+	switch {
+	case a.Kills != b.Kills:
+		return boolScore(a.Kills > b.Kills)
+	case a.Deaths != b.Deaths:
+		return boolScore(a.Deaths < b.Deaths)
+	default:
+		return 0.5
+	}
+}
+
+func boolScore(won bool) float64 {
+	if won {
+		return 1
+	}
+	return 0
+}
+
+type QueueStatus struct {
+	Queued    bool    `json:"queued"`
+	Position  int     `json:"position"` // 1 is next in line
+	QueueSize int     `json:"queueSize"`
+	Waited    float64 `json:"waited"` // seconds
+	Window    float64 `json:"window"` // current skill window
+	Rating    float64 `json:"rating"`
+	Games     int     `json:"games"`
+}
+
+// sendQueueStatus answers a queue_status request. Must be called with mu
+// held.
+func sendQueueStatus(client *Client) error {
+	now := time.Now()
+	queue := queuedClients()
+	rating := ratingOf(client.profile)
+	status := QueueStatus{QueueSize: len(queue), Rating: rating.Rating, Games: rating.Games}
+	for i, queued := range queue {
+		if queued == client {
+			status.Queued = true
+			status.Position = i + 1
+			status.Waited = now.Sub(client.queuedAt).Seconds()
+			status.Window = skillWindowFor(client, now)
+		}
+	}
+	return client.Conn.WriteJSON(Message{Type: "queue_status", Content: status})
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withClients swaps in the given clients and an empty rating table for the
+// length of the test.
+func withClients(t *testing.T, list ...*Client) {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	oldClients, oldRatings, oldMode := clients, ratings, gameMode
+	t.Cleanup(func() { clients, ratings, gameMode = oldClients, oldRatings, oldMode })
+	clients = make(map[*Client]bool)
+	for _, client := range list {
+		clients[client] = true
+	}
+	ratings = make(map[string]*Rating)
+	gameMode = ModeFFA
+}
+
+func ratedProfile(name string, class int) PlayerData {
+	return PlayerData{Nickname: name, HeroClass: class, Key: name + strings.Repeat("k", minPlayerKey)}
+}
+
+func TestRatingKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    PlayerData
+		rated   bool
+		samekey bool
+	}{
+		{name: "same player and class", a: ratedProfile("ann", 1), b: ratedProfile("ann", 1), rated: true, samekey: true},
+		{name: "other class", a: ratedProfile("ann", 1), b: ratedProfile("ann", 2), rated: true},
+		{name: "other player", a: ratedProfile("ann", 1), b: ratedProfile("bob", 1), rated: true},
+		{
+			// The nickname is for show, the key is who they are
+			name:  "renamed",
+			a:     PlayerData{Nickname: "ann", HeroClass: 1, Key: strings.Repeat("k", minPlayerKey)},
+			b:     PlayerData{Nickname: "bob", HeroClass: 1, Key: strings.Repeat("k", minPlayerKey)},
+			rated: true, samekey: true,
+		},
+		{name: "no key", a: PlayerData{Nickname: "ann", HeroClass: 1}},
+		{name: "short key", a: PlayerData{HeroClass: 1, Key: strings.Repeat("k", minPlayerKey-1)}},
+		{name: "long key", a: PlayerData{HeroClass: 1, Key: strings.Repeat("k", maxPlayerKey+1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := ratingKey(tt.a)
+			if (key != "") != tt.rated {
+				t.Fatalf("ratingKey(%+v) = %q, rated want %v", tt.a, key, tt.rated)
+			}
+			if !tt.rated {
+				return
+			}
+			if strings.Contains(key, tt.a.Key) {
+				t.Errorf("rating key %q gives the player's key away", key)
+			}
+			if same := key == ratingKey(tt.b); same != tt.samekey {
+				t.Errorf("keys %q and %q, same want %v", key, ratingKey(tt.b), tt.samekey)
+			}
+		})
+	}
+}
+
+func TestMatchScore(t *testing.T) {
+	stats := func(id, kills, deaths int) *PlayerStats {
+		return &PlayerStats{ID: id, Kills: kills, Deaths: deaths}
+	}
+	teams := map[int]int{1: 1, 2: 1, 3: 2}
+	tests := []struct {
+		name      string
+		a, b      *PlayerStats
+		standings map[int]float64
+		score     float64
+		opposed   bool
+	}{
+		{name: "more kills", a: stats(1, 3, 5), b: stats(3, 2, 0), score: 1, opposed: true},
+		{name: "fewer kills", a: stats(1, 1, 0), b: stats(3, 2, 5), score: 0, opposed: true},
+		{name: "same kills, fewer deaths", a: stats(1, 2, 1), b: stats(3, 2, 3), score: 1, opposed: true},
+		{name: "same kills and deaths", a: stats(1, 2, 1), b: stats(3, 2, 1), score: 0.5, opposed: true},
+		{name: "ffa teammates are still opponents", a: stats(1, 2, 1), b: stats(2, 0, 1), score: 1, opposed: true},
+		// Team modes go by the team result, not the scoreboard
+		{name: "winning team", a: stats(1, 0, 9), b: stats(3, 9, 0), standings: map[int]float64{1: 10, 2: 5}, score: 1, opposed: true},
+		{name: "losing team", a: stats(3, 9, 0), b: stats(1, 0, 9), standings: map[int]float64{1: 10, 2: 5}, score: 0, opposed: true},
+		{name: "teams tied", a: stats(1, 5, 0), b: stats(3, 0, 5), standings: map[int]float64{1: 5, 2: 5}, score: 0.5, opposed: true},
+		{name: "teammates", a: stats(1, 5, 0), b: stats(2, 0, 5), standings: map[int]float64{1: 10, 2: 5}, opposed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, opposed := matchScore(tt.a, tt.b, teams, tt.standings)
+			if opposed != tt.opposed || opposed && score != tt.score {
+				t.Errorf("got %v, %v, want %v, %v", score, opposed, tt.score, tt.opposed)
+			}
+		})
+	}
+}
+
+func TestUpdateRatings(t *testing.T) {
+	winner := &Client{Id: 1, profile: ratedProfile("ann", 1), playing: true}
+	loser := &Client{Id: 2, profile: ratedProfile("bob", 1), playing: true}
+	guest := &Client{Id: 3, profile: PlayerData{Nickname: "guest", HeroClass: 1}, playing: true}
+	benched := &Client{Id: 4, profile: ratedProfile("cid", 1), joined: true}
+	withClients(t, winner, loser, guest, benched)
+
+	results := []*PlayerStats{
+		{ID: 1, Kills: 3},
+		{ID: 3, Kills: 2},
+		{ID: 2, Kills: 1},
+		{ID: 99, Kills: 0}, // a bot
+	}
+	updateRatings(results)
+
+	// Even ratings, so the winner takes half of K from the loser
+	if got := ratingOf(winner.profile); got.Rating != initialRating+ratingK/2 || got.Games != 1 {
+		t.Errorf("winner %+v", got)
+	}
+	if got := ratingOf(loser.profile); got.Rating != initialRating-ratingK/2 || got.Games != 1 {
+		t.Errorf("loser %+v", got)
+	}
+	if len(ratings) != 2 {
+		t.Errorf("%d ratings, only the two keyed players in the match should be rated", len(ratings))
+	}
+
+	// The favourite gains less for beating the same player again
+	before := ratingOf(winner.profile).Rating
+	updateRatings(results)
+	if gain := ratingOf(winner.profile).Rating - before; gain >= ratingK/2 || gain <= 0 {
+		t.Errorf("favourite gained %v", gain)
+	}
+}
+
+func TestFormRoom(t *testing.T) {
+	now := time.Now()
+	queued := func(id int, waited time.Duration) *Client {
+		return &Client{Id: id, profile: ratedProfile(string(rune('a'+id)), 1), joined: true, ready: true, queuedAt: now.Add(-waited)}
+	}
+	tests := []struct {
+		name    string
+		queue   []*Client
+		ratings []float64 // by position in queue
+		want    []int
+	}{
+		{
+			name:    "close ratings",
+			queue:   []*Client{queued(1, 10*time.Second), queued(2, 5*time.Second), queued(3, 0)},
+			ratings: []float64{1000, 1050, 950},
+			want:    []int{1, 2, 3},
+		},
+		{
+			name:    "outside the window",
+			queue:   []*Client{queued(1, time.Second), queued(2, 0)},
+			ratings: []float64{1000, 1000 + skillWindow + 1},
+			want:    []int{1},
+		},
+		{
+			// Both have to accept, the newcomer's window is still narrow
+			name:    "only the anchor waited long",
+			queue:   []*Client{queued(1, time.Minute), queued(2, 0)},
+			ratings: []float64{1000, 1000 + skillWindow + 50},
+			want:    []int{1},
+		},
+		{
+			name:    "windows grow while waiting",
+			queue:   []*Client{queued(1, 11*time.Second), queued(2, 10*time.Second)},
+			ratings: []float64{1000, 1000 + skillWindow + 50},
+			want:    []int{1, 2},
+		},
+		{
+			name:  "playing already",
+			queue: []*Client{queued(1, 0), {Id: 2, profile: ratedProfile("c", 1), joined: true, ready: true, playing: true}},
+			want:  []int{1},
+		},
+		{
+			name:  "nobody queued",
+			queue: []*Client{{Id: 1, profile: ratedProfile("b", 1), joined: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withClients(t, tt.queue...)
+			for i, rating := range tt.ratings {
+				ratingFor(tt.queue[i].profile).Rating = rating
+			}
+			room := formRoom(now)
+			var got []int
+			for _, client := range room {
+				got = append(got, client.Id)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("room %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("room %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	t.Run("room size", func(t *testing.T) {
+		var queue []*Client
+		for i := 1; i <= matchMaxPlayers+2; i++ {
+			queue = append(queue, queued(i, time.Duration(i)*time.Second))
+		}
+		withClients(t, queue...)
+		if room := formRoom(now); len(room) != matchMaxPlayers {
+			t.Errorf("room of %d, want %d", len(room), matchMaxPlayers)
+		}
+	})
+}
+
+func TestLateJoiners(t *testing.T) {
+	now := time.Now()
+	playing := &Client{Id: 1, profile: ratedProfile("a", 1), joined: true, playing: true}
+	near := &Client{Id: 2, profile: ratedProfile("b", 1), joined: true, ready: true, queuedAt: now}
+	far := &Client{Id: 3, profile: ratedProfile("c", 1), joined: true, ready: true, queuedAt: now}
+	idle := &Client{Id: 4, profile: ratedProfile("d", 1), joined: true}
+	withClients(t, playing, near, far, idle)
+	ratingFor(far.profile).Rating = initialRating + skillWindow + 1
+
+	joiners := lateJoiners(now)
+	if len(joiners) != 1 || joiners[0] != near {
+		t.Errorf("got %d joiners, want only the one within their window", len(joiners))
+	}
+
+	// A full match takes nobody
+	old := matchMaxPlayers
+	defer func() { matchMaxPlayers = old }()
+	matchMaxPlayers = 1
+	if joiners := lateJoiners(now); len(joiners) != 0 {
+		t.Errorf("got %d joiners for a full match", len(joiners))
+	}
+}
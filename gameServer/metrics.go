@@ -0,0 +1,9 @@
+package main
+
+import "expvar"
+
+// Counters are published on /debug/vars next to the pprof endpoints.
+var (
+	rateLimitedMessages = expvar.NewMap("rate_limited_messages")
+	floodDisconnects    = expvar.NewInt("flood_disconnects")
+)
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+type MonsterClass struct {
+	ID                 int
+	Name               string
+	Health             float64
+	Speed              float64 // world units per tick
+	Radius             float64
+	Attack             float64
+	AttackRange        float64
+	AttackSpeed        int    // ms between attacks
+	AttackType         string // "melee" or "ranged"
+	MagicResistance    float64
+	PhysicalResistance float64
+	FirstWave          int // earliest wave the monster shows up in
+}
+
+var monsterClasses = map[int]MonsterClass{
+	1: {ID: 1, Name: "Zombie", Health: 60, Speed: 2.5, Radius: 14, Attack: 10, AttackRange: 10, AttackSpeed: 800, AttackType: "melee", PhysicalResistance: 0.2, FirstWave: 1},
+	2: {ID: 2, Name: "Spitter", Health: 40, Speed: 2, Radius: 12, Attack: 12, AttackRange: 250, AttackSpeed: 1500, AttackType: "ranged", MagicResistance: 0.3, FirstWave: 2},
+	3: {ID: 3, Name: "Brute", Health: 220, Speed: 1.5, Radius: 22, Attack: 30, AttackRange: 15, AttackSpeed: 1500, AttackType: "melee", PhysicalResistance: 0.4, FirstWave: 3},
+}
+
+const maxMonsterRadius = 22
+
+type Monster struct {
+	ID        int     `json:"id"`
+	Class     int     `json:"class"`
+	PosX      float64 `json:"posX"`
+	PosY      float64 `json:"posY"`
+	Radius    float64 `json:"radius"`
+	Health    float64 `json:"health"`
+	MaxHealth float64 `json:"maxHealth"`
+
+	lastAttack time.Time
+}
+
+var (
+	monsters      = make(map[int]*Monster) // guarded by mu
+	monsterGrid   = NewSpatialGrid(gridCellSize)
+	nextMonsterID int
+)
+
+// Wave settings. Wave n has waveBaseSize + (n-1)*waveGrowth monsters, each
+// with waveHealthGrowth more health than in the previous wave.
+var (
+	waveIntermission  = envDuration("WAVE_INTERMISSION", 10*time.Second)
+	waveSpawnInterval = envDuration("WAVE_SPAWN_INTERVAL", time.Second)
+	waveBaseSize      = envInt("WAVE_BASE_SIZE", 4)
+	waveGrowth        = envInt("WAVE_GROWTH", 2)
+	waveHealthGrowth  = envFloat("WAVE_HEALTH_GROWTH", 0.15)
+	monsterSpawnGap   = envFloat("MONSTER_SPAWN_GAP", 300) // minimum distance from players when spawning
+)
+
+type WaveState struct {
+	Number            int
+	toSpawn           int
+	nextSpawn         time.Time
+	intermissionUntil time.Time
+}
+
+var wave WaveState // guarded by mu
+
+func runPvE() {
+	ticker := time.NewTicker(tickRate)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		mu.Lock()
+		if match.state != MatchPlaying {
+			mu.Unlock()
+			continue
+		}
+		updateWaves(now)
+		for _, m := range monsters {
+			m.think(now)
+		}
+		mu.Unlock()
+	}
+}
+
+// updateWaves spawns the current wave and starts the next one once it's
+// cleared. Must be called with mu held.
+func updateWaves(now time.Time) {
+	if len(latestStates) == 0 {
+		if wave.Number > 0 {
+			log.Printf("All players died in wave %d", wave.Number)
+			broadcast <- Message{
+				Type:    "match_over",
+				Content: map[string]interface{}{"mode": ModePvE, "wave": wave.Number},
+			}
+			for id := range monsters {
+				removeMonster(id)
+			}
+			endMatch(fmt.Sprintf("Survived until wave %d", wave.Number))
+			wave = WaveState{}
+		}
+		return
+	}
+
+	if wave.toSpawn > 0 {
+		if now.After(wave.nextSpawn) {
+			spawnMonster()
+			wave.toSpawn--
+			wave.nextSpawn = now.Add(waveSpawnInterval)
+		}
+		return
+	}
+	if len(monsters) > 0 {
+		return
+	}
+
+	if wave.intermissionUntil.IsZero() {
+		wave.intermissionUntil = now.Add(waveIntermission)
+		if wave.Number > 0 {
+			broadcast <- Message{
+				Type:    "wave_cleared",
+				Content: map[string]interface{}{"wave": wave.Number, "nextIn": waveIntermission.Seconds()},
+			}
+		}
+		return
+	}
+	if now.After(wave.intermissionUntil) {
+		wave.Number++
+		wave.toSpawn = waveBaseSize + (wave.Number-1)*waveGrowth
+		wave.intermissionUntil = time.Time{}
+		log.Printf("Wave %d started with %d monsters", wave.Number, wave.toSpawn)
+		broadcast <- Message{
+			Type:    "wave_started",
+			Content: map[string]interface{}{"wave": wave.Number, "monsters": wave.toSpawn},
+		}
+	}
+}
+
+// spawnMonster adds a monster of a class unlocked in the current wave away
+// from all players. Must be called with mu held.
+func spawnMonster() {
+	var unlocked []MonsterClass
+	for _, class := range monsterClasses {
+		if class.FirstWave <= wave.Number {
+			unlocked = append(unlocked, class)
+		}
+	}
+	class := unlocked[(nextMonsterID+wave.Number)%len(unlocked)]
+
+	pos := spawnPosition()
+	for i := 0; i < 20 && len(playerGrid.QueryCircle(Circle{X: pos.X, Y: pos.Y, Radius: monsterSpawnGap})) > 0; i++ {
+		pos = spawnPosition()
+	}
+
+	nextMonsterID++
+	health := class.Health * (1 + waveHealthGrowth*float64(wave.Number-1))
+	m := &Monster{
+		ID:        nextMonsterID,
+		Class:     class.ID,
+		PosX:      pos.X,
+		PosY:      pos.Y,
+		Radius:    class.Radius,
+		Health:    health,
+		MaxHealth: health,
+	}
+	monsters[m.ID] = m
+	monsterGrid.Update(m.ID, pos)
+}
+
+// Must be called with mu held.
+func removeMonster(id int) {
+	delete(monsters, id)
+	monsterGrid.Remove(id)
+}
+
+// think moves the monster towards the closest player and attacks when in
+// range. Must be called with mu held.
+func (m *Monster) think(now time.Time) {
+	class := monsterClasses[m.Class]
+	pos := Vec2D{X: m.PosX, Y: m.PosY}
+
+	var target PlayerState
+	bestDist := math.Inf(1)
+	for _, player := range latestStates {
+		if dist := math.Hypot(player.PosX-pos.X, player.PosY-pos.Y); dist < bestDist {
+			target, bestDist = player, dist
+		}
+	}
+	if math.IsInf(bestDist, 1) {
+		return
+	}
+	targetPos := Vec2D{X: target.PosX, Y: target.PosY}
+
+	reach := class.AttackRange
+	if class.AttackType == "melee" {
+		reach += m.Radius + playerRadius
+	}
+	if bestDist > reach*0.9 {
+		m.moveTowards(nextWaypoint(pos, targetPos), class.Speed)
+	}
+
+	if bestDist > reach || now.Sub(m.lastAttack) < time.Duration(class.AttackSpeed)*time.Millisecond {
+		return
+	}
+	m.lastAttack = now
+	if class.AttackType == "ranged" {
+		AddMonsterProjectile(m.ID, pos, targetPos, class.AttackRange*1.2, class.Attack)
+		return
+	}
+	swing := Circle{X: m.PosX, Y: m.PosY, Radius: reach}
+	sendInView(pos, Message{
+		Type:    "melee_state",
+		Content: swing,
+	})
+	dealMonsterDamage(m.ID, target, class.Attack, DamagePhysical)
+	log.Printf("Player %d hit by monster %d", target.ID, m.ID)
+}
+
+// moveTowards steps towards the point, sliding along walls like players do.
+func (m *Monster) moveTowards(point Vec2D, speed float64) {
+	dir := NormalizedVector(Vec2D{X: m.PosX, Y: m.PosY}, point)
+	if next := (Vec2D{X: m.PosX + dir.X*speed, Y: m.PosY}); !arena.CircleBlocked(next, m.Radius) {
+		m.PosX = next.X
+	}
+	if next := (Vec2D{X: m.PosX, Y: m.PosY + dir.Y*speed}); !arena.CircleBlocked(next, m.Radius) {
+		m.PosY = next.Y
+	}
+	monsterGrid.Update(m.ID, Vec2D{X: m.PosX, Y: m.PosY})
+}
+
+// monstersInCircle returns the monsters touching the circle. Must be called
+// with mu held.
+func monstersInCircle(c Circle) []*Monster {
+	query := c
+	query.Radius += maxMonsterRadius
+	var hit []*Monster
+	for _, id := range monsterGrid.QueryCircle(query) {
+		m := monsters[id]
+		if c.Intersects(Circle{X: m.PosX, Y: m.PosY, Radius: m.Radius}) {
+			hit = append(hit, m)
+		}
+	}
+	return hit
+}
+
+// damageMonsters hits every monster in the area with the owner's attack, the
+// same way dealDamage does players. Must be called with mu held.
+func damageMonsters(ownerID int, area Arc, amount float64, damageType string) {
+	for _, m := range monstersInCircle(area.Circle) {
+		if area.Intersects(Circle{X: m.PosX, Y: m.PosY, Radius: m.Radius}) {
+			damageMonster(ownerID, m, amount, damageType)
+		}
+	}
+}
+
+// MonsterExplosion damages the players caught in the blast of a projectile
+// monsterID fired. Must be called with mu held.
+func MonsterExplosion(monsterID int, c Circle, damage float64) {
+	sendInView(Vec2D{X: c.X, Y: c.Y}, Message{
+		Type:    "explosion_state",
+		Content: c,
+	})
+	for _, playerID := range queryPlayers(c) {
+		player := latestStates[playerID]
+		if !c.Intersects(Circle{X: player.PosX, Y: player.PosY, Radius: playerRadius}) {
+			continue
+		}
+		dealMonsterDamage(monsterID, player, damage, DamageMagic)
+	}
+}
+
+// Must be called with mu held.
+func monsterPosition(id int) (Vec2D, bool) {
+	m, exists := monsters[id]
+	if !exists {
+		return Vec2D{}, false
+	}
+	return Vec2D{X: m.PosX, Y: m.PosY}, true
+}
+
+// sendMonsters sends the client the monsters in its area of interest. Must
+// be called with mu held.
+func sendMonsters(client *Client, now int64) error {
+	_, left := client.monstersInView.update(viewCenter(client), monsterGrid, monsterPosition)
+	if len(client.monstersInView) == 0 && len(left) == 0 {
+		return nil
+	}
+	states := make(map[int]Monster, len(client.monstersInView))
+	for id := range client.monstersInView {
+		states[id] = *monsters[id]
+	}
+	return client.Conn.WriteJSON(Message{
+		Type:    "monsters_update",
+		Tick:    serverTick,
+		Time:    now,
+		Content: states,
+	})
+}
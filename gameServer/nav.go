@@ -0,0 +1,32 @@
+package main
+
+import "gameServer/navigation"
+
+// Cells searched per tick across all path queries.
+var navBudget = envInt("NAV_BUDGET", 4000)
+
+var navPlanner *navigation.Planner // guarded by mu
+
+// initNavigation builds the walkability grid from the arena's tiles.
+func initNavigation() {
+	grid := navigation.NewGrid(arena.Width, arena.Height, arena.TileSize)
+	for y := 0; y < arena.Height; y++ {
+		for x := 0; x < arena.Width; x++ {
+			if arena.Tiles[y*arena.Width+x] == TileSolid {
+				grid.SetWalkable(navigation.Cell{X: x, Y: y}, false)
+			}
+		}
+	}
+	navPlanner = navigation.NewPlanner(grid, playerRadius, navBudget)
+}
+
+// nextWaypoint returns the point to head for on the way from pos to goal,
+// or the goal itself when no path could be found this tick. Must be called
+// with mu held.
+func nextWaypoint(pos, goal Vec2D) Vec2D {
+	path, err := navPlanner.FindPath(navigation.Point(pos), navigation.Point(goal))
+	if err != nil || len(path) < 2 {
+		return goal
+	}
+	return Vec2D(path[1])
+}
@@ -0,0 +1,171 @@
+package navigation
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+)
+
+var (
+	ErrNoPath         = errors.New("navigation: no path")
+	ErrBudgetExceeded = errors.New("navigation: search budget exceeded for this tick")
+)
+
+var neighbours = []struct {
+	dx, dy int
+	cost   float64
+}{
+	{1, 0, 1}, {-1, 0, 1}, {0, 1, 1}, {0, -1, 1},
+	{1, 1, math.Sqrt2}, {1, -1, math.Sqrt2}, {-1, 1, math.Sqrt2}, {-1, -1, math.Sqrt2},
+}
+
+type node struct {
+	cell  Cell
+	g, f  float64
+	index int
+}
+
+type openSet []*node
+
+func (s openSet) Len() int           { return len(s) }
+func (s openSet) Less(i, j int) bool { return s[i].f < s[j].f }
+func (s openSet) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+	s[i].index = i
+	s[j].index = j
+}
+func (s *openSet) Push(x any) {
+	n := x.(*node)
+	n.index = len(*s)
+	*s = append(*s, n)
+}
+func (s *openSet) Pop() any {
+	old := *s
+	n := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return n
+}
+
+// octile is the exact distance on a grid with diagonal moves, so the
+// heuristic never overestimates.
+func octile(a, b Cell) float64 {
+	dx := math.Abs(float64(a.X - b.X))
+	dy := math.Abs(float64(a.Y - b.Y))
+	return dx + dy + (math.Sqrt2-2)*math.Min(dx, dy)
+}
+
+// search is an A* search from start to goal. It can stop when it runs out of
+// budget and carry on later from where it left off.
+type search struct {
+	start, goal Cell
+	nodes       map[Cell]*node
+	cameFrom    map[Cell]Cell
+	closed      map[Cell]bool
+	open        *openSet
+}
+
+func newSearch(start, goal Cell) *search {
+	first := &node{cell: start, f: octile(start, goal)}
+	return &search{
+		start:    start,
+		goal:     goal,
+		nodes:    map[Cell]*node{start: first},
+		cameFrom: make(map[Cell]Cell),
+		closed:   make(map[Cell]bool),
+		open:     &openSet{first},
+	}
+}
+
+// astar searches from start to goal, expanding at most maxExpansions cells.
+// It returns the cells of the path and how many cells it expanded.
+func (g *Grid) astar(start, goal Cell, maxExpansions int) ([]Cell, int, error) {
+	return newSearch(start, goal).run(g, maxExpansions)
+}
+
+// run expands at most maxExpansions more cells. It returns the cells of the
+// path and how many cells it expanded in this call. After ErrBudgetExceeded
+// the search can be run again to continue it.
+func (s *search) run(g *Grid, maxExpansions int) ([]Cell, int, error) {
+	if !g.Walkable(s.start) || !g.Walkable(s.goal) {
+		return nil, 0, ErrNoPath
+	}
+
+	goal, closed, open := s.goal, s.closed, s.open
+	expanded := 0
+	for open.Len() > 0 {
+		current := (*open)[0]
+		if current.cell == goal {
+			return s.path(), expanded, nil
+		}
+		// Leave the node in the open set so the search can resume from it
+		if expanded >= maxExpansions {
+			return nil, expanded, ErrBudgetExceeded
+		}
+		heap.Pop(open)
+		expanded++
+		closed[current.cell] = true
+
+		for _, n := range neighbours {
+			next := Cell{X: current.cell.X + n.dx, Y: current.cell.Y + n.dy}
+			if closed[next] || !g.Walkable(next) {
+				continue
+			}
+			// No cutting corners past walls
+			if n.dx != 0 && n.dy != 0 &&
+				(!g.Walkable(Cell{X: current.cell.X + n.dx, Y: current.cell.Y}) || !g.Walkable(Cell{X: current.cell.X, Y: current.cell.Y + n.dy})) {
+				continue
+			}
+			cost := current.g + n.cost
+			if existing, seen := s.nodes[next]; seen {
+				if cost >= existing.g {
+					continue
+				}
+				existing.g = cost
+				existing.f = cost + octile(next, goal)
+				s.cameFrom[next] = current.cell
+				heap.Fix(open, existing.index)
+				continue
+			}
+			nn := &node{cell: next, g: cost, f: cost + octile(next, goal)}
+			s.nodes[next] = nn
+			s.cameFrom[next] = current.cell
+			heap.Push(open, nn)
+		}
+	}
+	return nil, expanded, ErrNoPath
+}
+
+// path walks back from the goal once the search reached it.
+func (s *search) path() []Cell {
+	path := []Cell{s.goal}
+	for c := s.goal; c != s.start; {
+		c = s.cameFrom[c]
+		path = append(path, c)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// smooth drops waypoints that can be skipped in a straight line, so entities
+// don't zig-zag from cell center to cell center.
+func (g *Grid) smooth(points []Point, radius float64) []Point {
+	if len(points) < 3 {
+		return points
+	}
+	smoothed := []Point{points[0]}
+	anchor := 0
+	for anchor < len(points)-1 {
+		next := anchor + 1
+		for i := len(points) - 1; i > anchor+1; i-- {
+			if g.LineOfSight(points[anchor], points[i], radius) {
+				next = i
+				break
+			}
+		}
+		smoothed = append(smoothed, points[next])
+		anchor = next
+	}
+	return smoothed
+}
@@ -0,0 +1,207 @@
+package navigation
+
+import (
+	"math"
+	"testing"
+)
+
+// gridFrom builds a grid from rows drawn top down, '#' is a wall.
+func gridFrom(rows ...string) *Grid {
+	g := NewGrid(len(rows[0]), len(rows), 10)
+	for i, row := range rows {
+		y := len(rows) - 1 - i
+		for x, c := range row {
+			if c == '#' {
+				g.SetWalkable(Cell{X: x, Y: y}, false)
+			}
+		}
+	}
+	return g
+}
+
+func pathCost(path []Cell) float64 {
+	cost := 0.0
+	for i := 1; i < len(path); i++ {
+		cost += math.Hypot(float64(path[i].X-path[i-1].X), float64(path[i].Y-path[i-1].Y))
+	}
+	return cost
+}
+
+func TestAstar(t *testing.T) {
+	tests := []struct {
+		name        string
+		grid        *Grid
+		start, goal Cell
+		err         error
+		cost        float64 // of the path found, when err is nil
+	}{
+		{
+			name:  "open diagonal",
+			grid:  gridFrom(".....", ".....", ".....", ".....", "....."),
+			start: Cell{0, 0}, goal: Cell{4, 4},
+			cost: 4 * math.Sqrt2,
+		},
+		{
+			name:  "around a wall",
+			grid:  gridFrom(".....", ".###.", ".....", ".....", "....."),
+			start: Cell{2, 2}, goal: Cell{2, 4},
+			cost: 6, // no corner cutting, so around both ends of the wall
+		},
+		{
+			name:  "blocked start",
+			grid:  gridFrom("...", ".#.", "..."),
+			start: Cell{1, 1}, goal: Cell{0, 0},
+			err: ErrNoPath,
+		},
+		{
+			name:  "blocked goal",
+			grid:  gridFrom("...", ".#.", "..."),
+			start: Cell{0, 0}, goal: Cell{1, 1},
+			err: ErrNoPath,
+		},
+		{
+			name:  "goal outside the grid",
+			grid:  gridFrom("...", "...", "..."),
+			start: Cell{0, 0}, goal: Cell{5, 5},
+			err: ErrNoPath,
+		},
+		{
+			name:  "unreachable goal",
+			grid:  gridFrom("..#..", "..#..", "..#..", "..#..", "..#.."),
+			start: Cell{0, 0}, goal: Cell{4, 4},
+			err: ErrNoPath,
+		},
+		{
+			// The only diagonal gap is between two walls touching at a
+			// corner, which can't be squeezed through
+			name:  "no corner cutting",
+			grid:  gridFrom("..#", "..#", "##."),
+			start: Cell{0, 1}, goal: Cell{2, 0},
+			err: ErrNoPath,
+		},
+		{
+			name:  "no cutting past one wall",
+			grid:  gridFrom("...", ".#.", "..."),
+			start: Cell{0, 1}, goal: Cell{1, 2},
+			cost: 2,
+		},
+		{
+			name:  "over budget",
+			grid:  gridFrom("..........", "..........", "..........", "..........", ".........."),
+			start: Cell{0, 0}, goal: Cell{9, 4},
+			err: ErrBudgetExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := 1000
+			if tt.err == ErrBudgetExceeded {
+				budget = 3
+			}
+			path, _, err := tt.grid.astar(tt.start, tt.goal, budget)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if path[0] != tt.start || path[len(path)-1] != tt.goal {
+				t.Fatalf("path %v doesn't go from %v to %v", path, tt.start, tt.goal)
+			}
+			for i, c := range path {
+				if !tt.grid.Walkable(c) {
+					t.Fatalf("path goes through wall %v", c)
+				}
+				if i == 0 {
+					continue
+				}
+				prev := path[i-1]
+				if c.X != prev.X && c.Y != prev.Y &&
+					(!tt.grid.Walkable(Cell{X: c.X, Y: prev.Y}) || !tt.grid.Walkable(Cell{X: prev.X, Y: c.Y})) {
+					t.Fatalf("path cuts the corner from %v to %v", prev, c)
+				}
+			}
+			if cost := pathCost(path); math.Abs(cost-tt.cost) > 1e-9 {
+				t.Errorf("path %v costs %v, want %v", path, cost, tt.cost)
+			}
+		})
+	}
+}
+
+func TestSearchResumes(t *testing.T) {
+	g := gridFrom(
+		"###########",
+		"...........",
+		"##########.",
+		"...........",
+	)
+	start, goal := Cell{0, 0}, Cell{0, 2}
+	full, want, err := g.astar(start, goal, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newSearch(start, goal)
+	total := 0
+	for {
+		path, expanded, err := s.run(g, 2)
+		total += expanded
+		if err == ErrBudgetExceeded {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pathCost(path) != pathCost(full) {
+			t.Errorf("resumed path %v, want %v", path, full)
+		}
+		break
+	}
+	if total != want {
+		t.Errorf("resumed search expanded %d cells, one go expands %d", total, want)
+	}
+}
+
+func TestSmooth(t *testing.T) {
+	tests := []struct {
+		name   string
+		grid   *Grid
+		points []Point
+		want   int
+	}{
+		{
+			name:   "straight line",
+			grid:   gridFrom(".....", ".....", "....."),
+			points: []Point{{5, 15}, {15, 15}, {25, 15}, {35, 15}, {45, 15}},
+			want:   2,
+		},
+		{
+			name:   "around a corner",
+			grid:   gridFrom(".....", ".###.", "....."),
+			points: []Point{{5, 5}, {15, 5}, {25, 5}, {35, 5}, {45, 5}, {45, 15}, {45, 25}},
+			want:   3,
+		},
+		{
+			name:   "too short to smooth",
+			grid:   gridFrom("..", ".."),
+			points: []Point{{5, 5}, {15, 15}},
+			want:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			smoothed := tt.grid.smooth(tt.points, 2)
+			if len(smoothed) != tt.want {
+				t.Fatalf("got %d waypoints %v, want %d", len(smoothed), smoothed, tt.want)
+			}
+			if smoothed[0] != tt.points[0] || smoothed[len(smoothed)-1] != tt.points[len(tt.points)-1] {
+				t.Errorf("smoothing moved the ends: %v", smoothed)
+			}
+			for i := 1; i < len(smoothed); i++ {
+				if !tt.grid.LineOfSight(smoothed[i-1], smoothed[i], 2) {
+					t.Errorf("no line of sight from %v to %v", smoothed[i-1], smoothed[i])
+				}
+			}
+		})
+	}
+}
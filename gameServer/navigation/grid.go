@@ -0,0 +1,101 @@
+// Package navigation finds paths around obstacles for server controlled
+// entities. It only knows about a walkability grid, so it can be used and
+// tested without the rest of the server.
+package navigation
+
+import "math"
+
+type Point struct {
+	X, Y float64
+}
+
+type Cell struct {
+	X, Y int
+}
+
+// Grid is a walkability grid laid over the world. Cell (0, 0) covers world
+// positions [0, CellSize) on both axes.
+type Grid struct {
+	Width    int
+	Height   int
+	CellSize float64
+	walkable []bool
+}
+
+// NewGrid returns a grid where every cell is walkable.
+func NewGrid(width, height int, cellSize float64) *Grid {
+	g := &Grid{
+		Width:    width,
+		Height:   height,
+		CellSize: cellSize,
+		walkable: make([]bool, width*height),
+	}
+	for i := range g.walkable {
+		g.walkable[i] = true
+	}
+	return g
+}
+
+func (g *Grid) inside(c Cell) bool {
+	return c.X >= 0 && c.Y >= 0 && c.X < g.Width && c.Y < g.Height
+}
+
+func (g *Grid) SetWalkable(c Cell, walkable bool) {
+	if g.inside(c) {
+		g.walkable[c.Y*g.Width+c.X] = walkable
+	}
+}
+
+// Walkable reports whether the cell can be entered. Cells outside the grid
+// never can.
+func (g *Grid) Walkable(c Cell) bool {
+	return g.inside(c) && g.walkable[c.Y*g.Width+c.X]
+}
+
+func (g *Grid) CellAt(p Point) Cell {
+	return Cell{
+		X: int(math.Floor(p.X / g.CellSize)),
+		Y: int(math.Floor(p.Y / g.CellSize)),
+	}
+}
+
+func (g *Grid) Center(c Cell) Point {
+	return Point{
+		X: (float64(c.X) + 0.5) * g.CellSize,
+		Y: (float64(c.Y) + 0.5) * g.CellSize,
+	}
+}
+
+// Clear reports whether a circle of the given radius fits at p.
+func (g *Grid) Clear(p Point, radius float64) bool {
+	for _, corner := range []Point{
+		{X: p.X - radius, Y: p.Y - radius},
+		{X: p.X + radius, Y: p.Y - radius},
+		{X: p.X - radius, Y: p.Y + radius},
+		{X: p.X + radius, Y: p.Y + radius},
+	} {
+		if !g.Walkable(g.CellAt(corner)) {
+			return false
+		}
+	}
+	return true
+}
+
+// LineOfSight reports whether a circle of the given radius can move in a
+// straight line from a to b without touching an unwalkable cell.
+func (g *Grid) LineOfSight(a, b Point, radius float64) bool {
+	dist := math.Hypot(b.X-a.X, b.Y-a.Y)
+	step := g.CellSize / 4
+	steps := int(math.Ceil(dist / step))
+	for i := 0; i <= steps; i++ {
+		t := 1.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+		p := Point{X: a.X + (b.X-a.X)*t, Y: a.Y + (b.Y-a.Y)*t}
+		if !g.Clear(p, radius) {
+			return false
+		}
+	}
+	return true
+}
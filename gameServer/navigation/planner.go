@@ -0,0 +1,109 @@
+package navigation
+
+type pathKey struct {
+	from, to Cell
+}
+
+type cachedPath struct {
+	cells []Cell
+	err   error // ErrNoPath is cached too
+	tick  uint64
+}
+
+// A search that ran out of budget, carried on when the path is asked for
+// again
+type pendingSearch struct {
+	search *search
+	tick   uint64 // last asked for
+}
+
+// Planner answers path queries on a grid. It limits the number of cells
+// searched per tick so many entities asking at once can't stall the server,
+// and caches paths so repeated queries between the same cells are free.
+// Unreachable goals are cached as well, so they aren't searched every tick.
+// Searches that run out of budget continue on the next tick instead of
+// starting over.
+// A Planner is not safe for concurrent use.
+type Planner struct {
+	grid     *Grid
+	radius   float64 // clearance used when smoothing paths
+	budget   int     // cell expansions allowed per tick
+	used     int
+	cacheTTL uint64 // ticks a cached path stays valid
+	maxCache int
+	tick     uint64
+	cache    map[pathKey]cachedPath
+	pending  map[pathKey]*pendingSearch
+}
+
+func NewPlanner(grid *Grid, radius float64, budget int) *Planner {
+	return &Planner{
+		grid:     grid,
+		radius:   radius,
+		budget:   budget,
+		cacheTTL: 60,
+		maxCache: 1024,
+		cache:    make(map[pathKey]cachedPath),
+		pending:  make(map[pathKey]*pendingSearch),
+	}
+}
+
+// Tick starts a new budget period. Call it once per server tick.
+func (p *Planner) Tick() {
+	p.tick++
+	p.used = 0
+	for key, pending := range p.pending {
+		// Nobody asked for it last tick, they've moved on
+		if p.tick-pending.tick > 1 {
+			delete(p.pending, key)
+		}
+	}
+	if len(p.cache) > p.maxCache {
+		for key, entry := range p.cache {
+			if p.tick-entry.tick > p.cacheTTL {
+				delete(p.cache, key)
+			}
+		}
+	}
+}
+
+// FindPath returns a smoothed list of waypoints from `from` to `to`,
+// starting with `from` and ending with `to`. It returns ErrBudgetExceeded
+// when this tick's budget ran out, the caller should try again next tick and
+// the search picks up where it stopped.
+func (p *Planner) FindPath(from, to Point) ([]Point, error) {
+	key := pathKey{from: p.grid.CellAt(from), to: p.grid.CellAt(to)}
+	entry, cached := p.cache[key]
+	if !cached || p.tick-entry.tick > p.cacheTTL {
+		pending, searching := p.pending[key]
+		if searching {
+			pending.tick = p.tick
+		}
+		if p.used >= p.budget {
+			return nil, ErrBudgetExceeded
+		}
+		if !searching {
+			pending = &pendingSearch{search: newSearch(key.from, key.to), tick: p.tick}
+			p.pending[key] = pending
+		}
+		cells, expanded, err := pending.search.run(p.grid, p.budget-p.used)
+		p.used += expanded
+		if err == ErrBudgetExceeded {
+			return nil, err
+		}
+		delete(p.pending, key)
+		entry = cachedPath{cells: cells, err: err, tick: p.tick}
+		p.cache[key] = entry
+	}
+	if entry.err != nil {
+		return nil, entry.err
+	}
+
+	points := make([]Point, 0, len(entry.cells)+1)
+	points = append(points, from)
+	for i := 1; i < len(entry.cells)-1; i++ {
+		points = append(points, p.grid.Center(entry.cells[i]))
+	}
+	points = append(points, to)
+	return p.grid.smooth(points, p.radius), nil
+}
@@ -0,0 +1,119 @@
+package navigation
+
+import "testing"
+
+func TestPlannerCache(t *testing.T) {
+	g := gridFrom(".....", ".###.", ".....", ".....", ".....")
+	p := NewPlanner(g, 2, 1000)
+	from, to := Point{25, 25}, Point{25, 45}
+
+	first, err := p.FindPath(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := p.used
+	if used == 0 {
+		t.Fatal("first query didn't search")
+	}
+
+	// Anywhere in the same cells hits the cache
+	second, err := p.FindPath(Point{22, 27}, Point{28, 43})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.used != used {
+		t.Errorf("cached query searched %d more cells", p.used-used)
+	}
+	if len(second) != len(first) {
+		t.Errorf("cached path %v, first %v", second, first)
+	}
+	if second[0] != (Point{22, 27}) || second[len(second)-1] != (Point{28, 43}) {
+		t.Errorf("cached path %v doesn't use the new end points", second)
+	}
+
+	// Expired entries are searched again
+	for i := uint64(0); i <= p.cacheTTL; i++ {
+		p.Tick()
+	}
+	if _, err := p.FindPath(from, to); err != nil {
+		t.Fatal(err)
+	}
+	if p.used == 0 {
+		t.Error("expired path came from the cache")
+	}
+}
+
+func TestPlannerCachesFailures(t *testing.T) {
+	g := gridFrom("..#..", "..#..", "..#..")
+	p := NewPlanner(g, 2, 1000)
+	from, to := Point{5, 5}, Point{45, 5}
+
+	if _, err := p.FindPath(from, to); err != ErrNoPath {
+		t.Fatalf("got %v, want ErrNoPath", err)
+	}
+	p.Tick()
+	if _, err := p.FindPath(from, to); err != ErrNoPath {
+		t.Fatalf("got %v from the cache, want ErrNoPath", err)
+	}
+	if p.used != 0 {
+		t.Errorf("unreachable goal searched again, %d cells", p.used)
+	}
+}
+
+func TestPlannerResumesOverTicks(t *testing.T) {
+	g := gridFrom(
+		"###########",
+		"...........",
+		"##########.",
+		"...........",
+	)
+	_, needed, err := g.astar(Cell{0, 0}, Cell{0, 2}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const budget = 3
+	p := NewPlanner(g, 2, budget)
+	from, to := Point{5, 5}, Point{5, 25}
+
+	ticks := 0
+	for {
+		_, err := p.FindPath(from, to)
+		if err == nil {
+			break
+		}
+		if err != ErrBudgetExceeded {
+			t.Fatal(err)
+		}
+		if len(p.pending) != 1 {
+			t.Fatalf("%d pending searches, want 1", len(p.pending))
+		}
+		ticks++
+		if ticks > needed {
+			t.Fatal("search never finished")
+		}
+		p.Tick()
+	}
+	if ticks == 0 {
+		t.Fatal("search fit in one tick, the budget is too big for this test")
+	}
+	// Starting over every tick would never get past the budget
+	if want := (needed + budget - 1) / budget; ticks > want {
+		t.Errorf("took %d ticks, resuming needs %d", ticks, want)
+	}
+	if len(p.pending) != 0 {
+		t.Errorf("finished search still pending")
+	}
+}
+
+func TestPlannerDropsAbandonedSearches(t *testing.T) {
+	g := gridFrom("..........", "..........", "..........")
+	p := NewPlanner(g, 2, 1)
+	if _, err := p.FindPath(Point{5, 5}, Point{95, 25}); err != ErrBudgetExceeded {
+		t.Fatalf("got %v, want ErrBudgetExceeded", err)
+	}
+	p.Tick()
+	p.Tick()
+	if len(p.pending) != 0 {
+		t.Errorf("abandoned search kept for %d ticks", 2)
+	}
+}
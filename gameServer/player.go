@@ -12,7 +12,11 @@ type PlayerState struct {
 	DirectionY  float64   `json:"directionY"`
 	LastAttack  time.Time `json:"lastAttack"`
 	IsAttacking bool      `json:"isAttacking"`
-	Health      float64   `json:"health"` //
+	Health      float64   `json:"health"`    //
+	LastInput   uint32    `json:"lastInput"` // last movement seq applied, for client reconciliation
+	Team        int       `json:"team"`      // 0 when not playing a team mode
+	// Active status effects, only filled in for snapshots
+	Statuses []StatusEffect `json:"statuses,omitempty"`
 }
 type PlayerMovement struct {
 	ID         int     `json:"id"`
@@ -20,16 +24,19 @@ type PlayerMovement struct {
 	DirectionY float64 `json:"directionY"`
 	MovingX    int     `json:"movingX"`
 	MovingY    int     `json:"movingY"`
+	Seq        uint32  `json:"seq"`
 }
 type PlayerAttack struct {
 	ID         int     `json:"id"`
 	DirectionX float64 `json:"directionX"`
 	DirectionY float64 `json:"directionY"`
+	ViewTick   uint64  `json:"viewTick"` // last snapshot tick the client had seen
 }
 
 type PlayerData struct {
 	HeroClass int    `json:"heroClass"`
 	Nickname  string `json:"nickname"`
+	Key       string `json:"key"` // random secret the client keeps between sessions, never sent to others
 }
 
 type PlayerClass struct {
@@ -40,8 +47,12 @@ type PlayerClass struct {
 	Speed              int     `json:"speed"`
 	Attack             float64 `json:"attack"`
 	AttackRange        float64 `json:"attackRange"`
+	AttackArc          float64 `json:"attackArc"` // degrees covered by melee swings, 0 for all around
 	AttackSpeed        int     `json:"attackSpeed"`
 	AttackType         string  `json:"attackType"`
+	CritChance         float64 `json:"critChance"`
+	CritMultiplier     float64 `json:"critMultiplier"`
+	ArmorPenetration   float64 `json:"armorPenetration"` // fraction of the target's resistance ignored
 }
 
 var WarriorClass = PlayerClass{
@@ -51,9 +62,13 @@ var WarriorClass = PlayerClass{
 	Health:             150,
 	Speed:              600,
 	Attack:             25,
-	AttackRange:        50,
+	AttackRange:        envFloat("WARRIOR_ATTACK_RANGE", 50),
+	AttackArc:          envFloat("WARRIOR_ATTACK_ARC", 120),
 	AttackSpeed:        300,
 	AttackType:         "physical",
+	CritChance:         0.1,
+	CritMultiplier:     1.5,
+	ArmorPenetration:   0.2,
 }
 var MageClass = PlayerClass{
 	ID:                 2,
@@ -62,9 +77,12 @@ var MageClass = PlayerClass{
 	Speed:              400,
 	Health:             100,
 	Attack:             30,
-	AttackRange:        200,
+	AttackRange:        envFloat("MAGE_ATTACK_RANGE", 200),
 	AttackSpeed:        500,
 	AttackType:         "magic",
+	CritChance:         0.05,
+	CritMultiplier:     2,
+	ArmorPenetration:   0.25,
 }
 
 var classMap = map[int]PlayerClass{WarriorClass.ID: WarriorClass, MageClass.ID: MageClass}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"log"
 	"math"
 	"sync"
 	"time"
@@ -17,6 +16,12 @@ type ServerProjectile struct {
 	MaxRange  float64
 	Distance  float64
 	CreatedAt time.Time
+	Rewind    uint64 // ticks to rewind targets by, fixed at spawn
+
+	// Monster projectiles only hit players, for a fixed amount. OwnerID is
+	// the monster that fired it.
+	FromMonster bool
+	Damage      float64
 }
 type ProjectileState struct {
 	PosX float64 `json:"posX"`
@@ -38,7 +43,10 @@ var pmu sync.Mutex
 //			nextID:      1,
 //		}
 //	}
-func AddProjectile(ownerID int, pos, dir Vec2D, maxRange float64) int {
+
+// AddProjectile spawns a projectile that tests hits against targets as they
+// were seen from viewTick. Must be called with mu held.
+func AddProjectile(ownerID int, pos, dir Vec2D, maxRange float64, viewTick uint64) int {
 	// log.Println("adding projectile:", ownerID, pos, dir, maxRange)
 	pmu.Lock()
 	defer pmu.Unlock()
@@ -55,56 +63,53 @@ func AddProjectile(ownerID int, pos, dir Vec2D, maxRange float64) int {
 		MaxRange:  maxRange,
 		Distance:  0,
 		CreatedAt: time.Now(),
+		Rewind:    serverTick - viewTick,
 	}
 
 	projectiles[id] = proj
 	// log.Println("projectile manager: ", projectiles)
 	return id
 }
-func AddMelee(ownerID int, pos Vec2D, maxRange float64) {
-	circle := Circle{X: pos.X, Y: pos.Y, Radius: maxRange}
-	broadcast <- Message{
-		Type:    "melee_state",
-		Content: circle,
-	}
-	for playerID, player := range latestStates {
 
-		if player.ID == ownerID {
-			continue
-		}
-		playerCircle := Circle{
-			X:      player.PosX,
-			Y:      player.PosY,
-			Radius: 15,
-		}
+// AddMonsterProjectile fires a projectile from a monster at target. Must be
+// called with mu held.
+func AddMonsterProjectile(monsterID int, pos, target Vec2D, maxRange, damage float64) {
+	pmu.Lock()
+	defer pmu.Unlock()
 
-		if circle.Intersects(playerCircle) {
-			// Get owner's class for damage calculation
-			if owner, exists := latestStates[ownerID]; exists {
-				attackType := classMap[owner.HeroClass].AttackType
-				attack := classMap[owner.HeroClass].Attack
-				// Update player state
-
-				attack = attack - (attack * classMap[latestStates[player.ID].HeroClass].MagicResistance)
-				player.Health -= attack
-
-				if player.Health <= 0 {
-					broadcast <- Message{
-						ClientID: player.ID,
-						Type:     "player_died",
-					}
-
-					delete(latestStates, playerID)
-					log.Printf("Player %d died", playerID)
-					break
-				}
-				latestStates[playerID] = player // Save updated state
-
-				log.Printf("Player %d hit by %s from player %d for %f damage",
-					playerID, attackType, ownerID, attack)
-			}
-		}
+	id := nextID
+	nextID++
+	projectiles[id] = ServerProjectile{
+		ID:          id,
+		OwnerID:     monsterID,
+		Pos:         pos,
+		Direction:   target,
+		Speed:       7,
+		MaxRange:    maxRange,
+		CreatedAt:   time.Now(),
+		FromMonster: true,
+		Damage:      damage,
+	}
+}
+
+// AddMelee hits everyone in range of the attacker within an arc of the given
+// degrees towards aim, using target positions at the given tick. An arc of 0
+// or 360 hits all around. Returns the players hit. Must be called with mu
+// held.
+func AddMelee(ownerID int, pos, aim Vec2D, maxRange, arc float64, tick uint64) []int {
+	swing := Arc{
+		Circle:    Circle{X: pos.X, Y: pos.Y, Radius: maxRange},
+		Direction: math.Atan2(aim.Y-pos.Y, aim.X-pos.X),
+		Width:     2 * math.Pi,
+	}
+	if arc > 0 && arc < 360 {
+		swing.Width = arc * math.Pi / 180
 	}
+	sendInView(pos, Message{
+		Type:    "melee_state",
+		Content: swing,
+	})
+	return damageArea(ownerID, swing, tick, DamagePhysical)
 }
 
 // Функция для вычисления нормализованного вектора по двум точкам
@@ -125,7 +130,12 @@ func NormalizedVector(a, b Vec2D) Vec2D {
 		Y: dy / length,
 	}
 }
+
+// projUpdate moves projectiles and resolves their hits. Locks are always
+// taken mu before pmu, the same order as the attack handler.
 func projUpdate() {
+	mu.Lock()
+	defer mu.Unlock()
 	pmu.Lock()
 	defer pmu.Unlock()
 
@@ -146,55 +156,57 @@ func projUpdate() {
 		// Update distance traveled
 		proj.Distance += math.Sqrt(movement.X*movement.X + movement.Y*movement.Y)
 		projectiles[projID] = proj
+		projectileGrid.Update(projID, proj.Pos)
 
-		mu.Lock()
-		for playerID, player := range latestStates {
-			if player.ID == proj.OwnerID {
-				continue
-			}
-			circle := Circle{
-				X:      proj.Pos.X,
-				Y:      proj.Pos.Y,
-				Radius: 5,
-			}
-			playerCircle := Circle{
-				X:      player.PosX,
-				Y:      player.PosY,
-				Radius: 15,
-			}
-			if circle.Intersects(playerCircle) {
-				// Get owner's class for damage calculation
-				if _, exists := latestStates[proj.OwnerID]; exists {
-					// Update player state
-
-					latestStates[playerID] = player // Save updated state
-
-					// log.Printf("Player %d hit by projectile %d from player %d for %d damage", playerID, projID, proj.OwnerID, classMap[owner.HeroClass].Attack)
-
-					// Remove projectile after hit
-					delete(projectiles, projID)
-					circle.Radius = 30
-					SendExplosion(proj.OwnerID, circle) // Send hit effect
-
-					// Break inner loop since projectile is destroyed
-					break
-				}
-			}
+		tick := serverTick - proj.Rewind
+
+		// Walls and targets stop projectiles, otherwise they blow up at
+		// max range
+		if arena.CircleBlocked(proj.Pos, 5) || projectileHit(proj, tick) || proj.Distance >= proj.MaxRange {
+			explodeProjectile(projID, proj, tick)
 		}
-		mu.Unlock()
-		// Remove projectile if it exceeded max range
-		if proj.Distance >= proj.MaxRange {
-			blowUp := Circle{
-				X:      proj.Pos.X,
-				Y:      proj.Pos.Y,
-				Radius: 30,
-			}
-			SendExplosion(proj.OwnerID, blowUp)
-			delete(projectiles, projID)
+	}
+}
 
+// projectileHit reports whether the projectile touches anything it can
+// damage. Must be called with mu held.
+func projectileHit(proj ServerProjectile, tick uint64) bool {
+	circle := Circle{
+		X:      proj.Pos.X,
+		Y:      proj.Pos.Y,
+		Radius: 5,
+	}
+	for _, playerID := range queryPlayers(circle) {
+		if !proj.FromMonster && !canHitPlayer(proj.OwnerID, playerID) {
+			continue
+		}
+		targetPos := positionAt(playerID, tick)
+		playerCircle := Circle{
+			X:      targetPos.X,
+			Y:      targetPos.Y,
+			Radius: playerRadius,
+		}
+		if circle.Intersects(playerCircle) {
+			return true
 		}
+	}
+	return !proj.FromMonster && len(monstersInCircle(circle)) > 0
+}
 
-		log.Println("projectile:", proj)
+// explodeProjectile removes the projectile and damages everything around it.
+// Must be called with mu and pmu held.
+func explodeProjectile(projID int, proj ServerProjectile, tick uint64) {
+	delete(projectiles, projID)
+	projectileGrid.Remove(projID)
+	blast := Circle{
+		X:      proj.Pos.X,
+		Y:      proj.Pos.Y,
+		Radius: 30,
+	}
+	if proj.FromMonster {
+		MonsterExplosion(proj.OwnerID, blast, proj.Damage)
+	} else {
+		SendExplosion(proj.OwnerID, blast, tick)
 	}
 }
 
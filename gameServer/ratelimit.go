@@ -0,0 +1,101 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+type RateLimit struct {
+	Rate  float64 // tokens added per second
+	Burst float64 // bucket capacity
+}
+
+// Per message type limits for every connection. Types not listed here use
+// defaultRateLimit.
+var rateLimits = map[string]RateLimit{
+	"player_moving":  {Rate: envFloat("RATE_MOVING", 30), Burst: envFloat("RATE_MOVING_BURST", 10)},
+	"player_attack":  {Rate: envFloat("RATE_ATTACK", 10), Burst: envFloat("RATE_ATTACK_BURST", 5)},
+	"new_player":     {Rate: envFloat("RATE_NEW_PLAYER", 1), Burst: envFloat("RATE_NEW_PLAYER_BURST", 2)},
+	"player_ability": {Rate: envFloat("RATE_ABILITY", 10), Burst: envFloat("RATE_ABILITY_BURST", 5)},
+	"chat_send":      {Rate: envFloat("RATE_CHAT", 1), Burst: envFloat("RATE_CHAT_BURST", 5)},
+	"ping":           {Rate: envFloat("RATE_PING", 2), Burst: envFloat("RATE_PING_BURST", 3)},
+	"spectator_view": {Rate: envFloat("RATE_SPECTATOR_VIEW", 20), Burst: envFloat("RATE_SPECTATOR_VIEW_BURST", 10)},
+}
+
+var defaultRateLimit = RateLimit{Rate: envFloat("RATE_DEFAULT", 10), Burst: envFloat("RATE_DEFAULT_BURST", 10)}
+
+// A client that has more than floodMaxViolations messages dropped within
+// floodWindow is disconnected.
+var (
+	floodMaxViolations = envInt("FLOOD_MAX_VIOLATIONS", 50)
+	floodWindow        = envDuration("FLOOD_WINDOW", 5*time.Second)
+)
+
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	return &tokenBucket{
+		limit:  limit,
+		tokens: limit.Burst,
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) Allow(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if b.tokens > b.limit.Burst {
+		b.tokens = b.limit.Burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// allowMessage is only called from the client's own read loop, so the
+// buckets don't need locking.
+func (c *Client) allowMessage(msgType string) bool {
+	if c.limiters == nil {
+		c.limiters = make(map[string]*tokenBucket)
+	}
+	// Types without their own limit share one bucket, so a client can't make
+	// up new type names to get fresh buckets
+	key := msgType
+	limit, ok := rateLimits[msgType]
+	if !ok {
+		key, limit = "default", defaultRateLimit
+	}
+	bucket, exists := c.limiters[key]
+	if !exists {
+		bucket = newTokenBucket(limit)
+		c.limiters[key] = bucket
+	}
+
+	now := time.Now()
+	if bucket.Allow(now) {
+		return true
+	}
+
+	rateLimitedMessages.Add(key, 1)
+	if now.Sub(c.floodStart) > floodWindow {
+		c.floodStart = now
+		c.violations = 0
+	}
+	c.violations++
+	return false
+}
+
+func (c *Client) isFlooding() bool {
+	if c.violations > floodMaxViolations {
+		floodDisconnects.Add(1)
+		log.Printf("Client %d is flooding, disconnecting", c.Id)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"golang.org/x/exp/rand"
+)
+
+// Battle royale settings
+var (
+	brPhases       = envInt("BR_PHASES", 5)
+	brPhaseWait    = envDuration("BR_PHASE_WAIT", 30*time.Second)
+	brPhaseShrink  = envDuration("BR_PHASE_SHRINK", 20*time.Second)
+	brShrinkFactor = envFloat("BR_SHRINK_FACTOR", 0.6)    // next radius as a fraction of the current one
+	brZoneDamage   = envFloat("BR_ZONE_DAMAGE", 5)        // per second outside the zone in the first phase
+	brDamageGrowth = envFloat("BR_ZONE_DAMAGE_GROWTH", 2) // multiplier for each later phase
+)
+
+// Credited with kills by the zone, never a real player ID
+const zoneKillerID = -1
+
+// SafeZone shrinks from Current to Next once the wait is over, then picks a
+// smaller Next inside it for the following phase.
+type SafeZone struct {
+	Current   Circle  `json:"current"`
+	Next      Circle  `json:"next"`
+	Phase     int     `json:"phase"`
+	Shrinking bool    `json:"shrinking"`
+	Countdown float64 `json:"countdown"` // seconds until the zone starts or stops shrinking
+	Damage    float64 `json:"damage"`    // per second outside the zone
+	// Current when the shrink started and how long it takes, so clients can
+	// shrink it smoothly between updates
+	Start      Circle  `json:"start"`
+	ShrinkTime float64 `json:"shrinkTime"`
+
+	phaseEnd  time.Time
+	contested bool // set once two players are alive, so there can be a winner
+}
+
+var safeZone SafeZone // guarded by mu
+
+func runBattleRoyale() {
+	ticker := time.NewTicker(tickRate)
+	defer ticker.Stop()
+
+	sender := modeStateSender[SafeZone]{
+		every:    modeStateEvery,
+		snapshot: func() SafeZone { return safeZone },
+		changed:  safeZoneChanged,
+		send:     sendSafeZone,
+	}
+	for now := range ticker.C {
+		mu.Lock()
+		if match.state == MatchPlaying {
+			updateSafeZone(now)
+			damageOutsideZone(tickRate.Seconds())
+			checkLastStanding()
+		}
+		sender.update(now)
+		mu.Unlock()
+	}
+}
+
+// safeZoneChanged reports whether a phase started or ended. Clients work out
+// the shrinking themselves.
+func safeZoneChanged(sent, current SafeZone) bool {
+	return current.Phase != sent.Phase || current.Shrinking != sent.Shrinking || current.Next != sent.Next
+}
+
+// Must be called with mu held.
+func sendSafeZone(now time.Time) {
+	safeZone.Countdown = math.Max(0, safeZone.phaseEnd.Sub(now).Seconds())
+	sendToMatch(Message{Type: "safe_zone", Content: safeZone})
+}
+
+// resetSafeZone starts a new round with the zone covering the whole arena
+// and brings back any bots that died. Must be called with mu held.
+func resetSafeZone(now time.Time) {
+	width, height := arena.WorldSize()
+	full := Circle{X: width / 2, Y: height / 2, Radius: math.Hypot(width, height) / 2}
+	safeZone = SafeZone{Current: full, Damage: brZoneDamage, ShrinkTime: brPhaseShrink.Seconds(), phaseEnd: now.Add(brPhaseWait)}
+	safeZone.Next = shrinkCircle(full)
+	for id, b := range bots {
+		if _, alive := latestStates[id]; !alive {
+			b.spawn()
+		}
+	}
+}
+
+// shrinkCircle picks a smaller circle that fits inside c.
+func shrinkCircle(c Circle) Circle {
+	radius := c.Radius * brShrinkFactor
+	angle := rand.Float64() * 2 * math.Pi
+	offset := rand.Float64() * (c.Radius - radius)
+	return Circle{
+		X:      c.X + offset*math.Cos(angle),
+		Y:      c.Y + offset*math.Sin(angle),
+		Radius: radius,
+	}
+}
+
+// Must be called with mu held.
+func updateSafeZone(now time.Time) {
+	z := &safeZone
+	if !z.Shrinking {
+		if now.After(z.phaseEnd) && z.Phase < brPhases {
+			z.Shrinking = true
+			z.Start = z.Current
+			z.phaseEnd = now.Add(brPhaseShrink)
+		}
+		return
+	}
+
+	t := 1 - z.phaseEnd.Sub(now).Seconds()/brPhaseShrink.Seconds()
+	if t < 1 {
+		z.Current = Circle{
+			X:      z.Start.X + (z.Next.X-z.Start.X)*t,
+			Y:      z.Start.Y + (z.Next.Y-z.Start.Y)*t,
+			Radius: z.Start.Radius + (z.Next.Radius-z.Start.Radius)*t,
+		}
+		return
+	}
+
+	z.Current = z.Next
+	z.Phase++
+	z.Shrinking = false
+	z.Damage = brZoneDamage * math.Pow(brDamageGrowth, float64(z.Phase))
+	z.phaseEnd = now.Add(brPhaseWait)
+	if z.Phase < brPhases {
+		z.Next = shrinkCircle(z.Current)
+	}
+	log.Printf("Safe zone phase %d, radius %.0f", z.Phase, z.Current.Radius)
+}
+
+// damageOutsideZone hurts everyone outside the safe zone through the same
+// death path as explosions. Must be called with mu held.
+func damageOutsideZone(dt float64) {
+	for _, player := range latestStates {
+		if math.Hypot(player.PosX-safeZone.Current.X, player.PosY-safeZone.Current.Y) <= safeZone.Current.Radius {
+			continue
+		}
+		dealDamage(zoneKillerID, player, safeZone.Damage*dt, DamageTrue)
+	}
+}
+
+// checkLastStanding ends the round once only one player is left alive. Must
+// be called with mu held.
+func checkLastStanding() {
+	alive := len(latestStates)
+	if alive >= 2 {
+		safeZone.contested = true
+	}
+	if !safeZone.contested || alive > 1 {
+		return
+	}
+	winner := 0
+	for id := range latestStates {
+		winner = id
+	}
+	if winner == 0 {
+		log.Println("Battle royale ended with nobody left")
+	} else {
+		log.Printf("Battle royale won by player %d", winner)
+	}
+	broadcast <- Message{Type: "br_won", Content: map[string]interface{}{"winner": winner}}
+	if winner == 0 {
+		endMatch("Nobody survived")
+	} else {
+		endMatch(latestStates[winner].Nickname + " wins")
+	}
+}
+
+// safeZonePoint is a walkable spot inside the next safe zone, for bots to
+// head to.
+func safeZonePoint() Vec2D {
+	c := safeZone.Next
+	for i := 0; i < 20; i++ {
+		angle := rand.Float64() * 2 * math.Pi
+		dist := math.Sqrt(rand.Float64()) * c.Radius
+		pos := Vec2D{X: c.X + dist*math.Cos(angle), Y: c.Y + dist*math.Sin(angle)}
+		if !arena.CircleBlocked(pos, playerRadius) {
+			return pos
+		}
+	}
+	return spawnPosition()
+}
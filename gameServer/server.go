@@ -17,11 +17,35 @@ import (
 type Client struct {
 	Conn *websocket.Conn
 	Id   int
+
+	limiters   map[string]*tokenBucket
+	violations int
+	floodStart time.Time
+
+	profile PlayerData // nickname and class picked in the menu
+	joined  bool       // has sent its profile and is in the lobby
+	ready   bool       // ready for the next match, which puts it in the matchmaking queue
+	playing bool       // spawned into the current match
+	team    int        // team in the current match, kept while dead
+
+	mutedUntil time.Time // can't chat until then
+
+	spectator bool // watches without a player, never in latestStates
+	dropped   bool // a write failed, skipped until its read loop cleans it up
+
+	queuedAt time.Time
+
+	view              Vec2D // last area of interest center
+	playersInView     interestSet
+	projectilesInView interestSet
+	monstersInView    interestSet
 }
 
 type Message struct {
 	ClientID int         `json:"client_id"`
 	Type     string      `json:"type"`
+	Tick     uint64      `json:"tick,omitempty"`
+	Time     int64       `json:"time,omitempty"` // server clock when the snapshot was taken, unix ms
 	Content  interface{} `json:"content"`
 }
 
@@ -56,15 +80,76 @@ func (c1 Circle) Intersects(c2 Circle) bool {
 	return distance <= (c1.Radius + c2.Radius)
 }
 
+// Arc is the slice of a circle within Width/2 radians either side of
+// Direction. A Width of 2π covers the whole circle.
+type Arc struct {
+	Circle
+	Direction float64
+	Width     float64
+}
+
+func fullCircle(c Circle) Arc {
+	return Arc{Circle: c, Width: 2 * math.Pi}
+}
+
+// Intersects reports whether any part of c is inside the arc.
+func (a Arc) Intersects(c Circle) bool {
+	if !a.Circle.Intersects(c) {
+		return false
+	}
+	if a.Width >= 2*math.Pi {
+		return true
+	}
+	off := math.Abs(math.Remainder(math.Atan2(c.Y-a.Y, c.X-a.X)-a.Direction, 2*math.Pi))
+	if off <= a.Width/2 {
+		return true
+	}
+	// Outside the wedge the closest part of the arc is one of its edges
+	center := Vec2D{X: c.X, Y: c.Y}
+	for _, side := range []float64{-1, 1} {
+		angle := a.Direction + side*a.Width/2
+		end := Vec2D{X: a.X + a.Radius*math.Cos(angle), Y: a.Y + a.Radius*math.Sin(angle)}
+		if segmentDistance(center, Vec2D{X: a.X, Y: a.Y}, end) <= c.Radius {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentDistance is the distance from p to the closest point between a and
+// b.
+func segmentDistance(p, a, b Vec2D) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/length))
+	}
+	return math.Hypot(p.X-a.X-t*dx, p.Y-a.Y-t*dy)
+}
+
 // Add a buffered channel for broadcasts
 var broadcast = make(chan Message, broadcastQueueSize)
 
-// Calculate the largest window size that fits on the screen while maintaining the aspect ratio
-var winWidth, winHeight = 1152.0, 864.0
+// Size of the arena in world units, independent of the clients' windows
+var (
+	worldWidth  = envFloat("WORLD_WIDTH", 3000)
+	worldHeight = envFloat("WORLD_HEIGHT", 2000)
+)
 
 func main() {
 
 	errChan := make(chan error, 1)
+	rand.Seed(uint64(time.Now().UnixNano()))
+
+	var err error
+	if arena, err = loadArena(); err != nil {
+		log.Fatal(err)
+	}
+	worldWidth, worldHeight = arena.WorldSize()
+	if err := initTeams(); err != nil {
+		log.Fatal(err)
+	}
+	initNavigation()
 
 	// Add pprof endpoints
 	mux := http.NewServeMux()
@@ -78,7 +163,25 @@ func main() {
 	go updateProjectiles()
 
 	go broadcastLatestStates()
-	ID := 1
+	go runBots()
+	loadRatings()
+	go runMatch()
+	go runAbilities()
+	go runStatuses()
+	switch gameMode {
+	case ModePvE:
+		go runPvE()
+	case ModeCTF:
+		initFlags()
+		go runCTF()
+	case ModeKOTH:
+		initZones()
+		go runKOTH()
+	case ModeBR:
+		resetSafeZone(time.Now())
+		go runBattleRoyale()
+	}
+	http.HandleFunc("/admin/mute", handleMute)
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
@@ -90,10 +193,12 @@ func main() {
 		conn.SetReadLimit(maxMessageSize)
 
 		client := &Client{
-			Conn: conn,
-			Id:   ID,
+			Conn:              conn,
+			Id:                newPlayerID(),
+			playersInView:     make(interestSet),
+			projectilesInView: make(interestSet),
+			monstersInView:    make(interestSet),
 		}
-		ID++
 		mu.Lock()
 		clients[client] = true
 		mu.Unlock()
@@ -117,43 +222,19 @@ func main() {
 			return
 		}
 		log.Println("New player data: ", newPlayer)
-		rand.Seed(uint64(time.Now().UnixNano()))
-		randomX := 20 + rand.Float64()*(winWidth-40)
-		randomY := 20 + rand.Float64()*(winHeight-40)
-		// Send welcome message
-		createMsg := Message{
-			Type: "new_player",
-			Content: map[string]interface{}{
-				"id": client.Id,
-				"X":  randomX,
-				"Y":  randomY,
-				// "heroClass": classMap[playerData.HeroClass].ID,
-				"HP": classMap[newPlayer.HeroClass].Health,
-			},
+		mu.Lock()
+		err = conn.WriteJSON(Message{Type: "map_data", Content: arena})
+		if err == nil && (gameMode == ModeTDM || gameMode == ModeCTF) {
+			err = conn.WriteJSON(teamScoreMessage())
 		}
-		log.Println(createMsg)
-		var newPlayerState = PlayerState{
-			ID:        client.Id,
-			PosX:      randomX,
-			PosY:      randomY,
-			HeroClass: newPlayer.HeroClass,
-			Nickname:  newPlayer.Nickname,
-			Health:    classMap[newPlayer.HeroClass].Health,
+		if err == nil && msg.Type == "spectate" {
+			err = spectate(client)
+		} else if err == nil {
+			err = joinMatch(client, newPlayer)
 		}
-		mu.Lock()
-		latestStates[client.Id] = newPlayerState
 		mu.Unlock()
-
-		jsonData, err := json.Marshal(createMsg)
-		if err != nil {
-			log.Println("JSON Marshal error:", err)
-			return
-		}
-		log.Println(createMsg)
-		// Отправляем данные по WebSocket
-		err = conn.WriteMessage(websocket.TextMessage, jsonData)
 		if err != nil {
-			log.Println("WriteMessage error:", err)
+			log.Println("Error sending welcome:", err)
 		}
 		go handleClientStates(client, clients, broadcast, errChan)
 	})
@@ -177,9 +258,15 @@ func handleClientStates(client *Client, clients map[*Client]bool, broadcast chan
 			break
 		}
 
+		if !client.allowMessage(msg.Type) {
+			if client.isFlooding() {
+				break
+			}
+			continue
+		}
+
 		switch msg.Type {
 		case "player_moving":
-			log.Println("player moving: ", msg)
 			var movement PlayerMovement
 			data, err := json.Marshal(msg.Content)
 			if err != nil {
@@ -192,34 +279,15 @@ func handleClientStates(client *Client, clients map[*Client]bool, broadcast chan
 				return
 			}
 
-			// Now you can use the movement data
+			// Clients can only move their own player
+			movement.ID = client.Id
 			mu.Lock()
-			if state, exists := latestStates[movement.ID]; exists {
-				// Update player position based on movement
-				if 1 >= movement.MovingX && movement.MovingX >= -1 && 1 >= movement.MovingY && movement.MovingY >= -1 {
-					if (state.PosX-15) >= 0 && movement.MovingX == -1 {
-						state.PosX += float64(movement.MovingX) * (float64(classMap[latestStates[movement.ID].HeroClass].Speed) / 100)
-					}
-					if (state.PosX+15) <= winWidth && movement.MovingX == 1 {
-						state.PosX += float64(movement.MovingX) * (float64(classMap[latestStates[movement.ID].HeroClass].Speed) / 100)
-					}
-					if (state.PosY-15) >= 0 && movement.MovingY == -1 {
-						state.PosY += float64(movement.MovingY) * (float64(classMap[latestStates[movement.ID].HeroClass].Speed) / 100)
-					}
-					if (state.PosY+15) <= winHeight && movement.MovingY == 1 {
-						state.PosY += float64(movement.MovingY) * (float64(classMap[latestStates[movement.ID].HeroClass].Speed) / 100)
-					}
-
-					state.DirectionX = movement.DirectionX
-					state.DirectionY = movement.DirectionY
-					latestStates[movement.ID] = state
-					// log.Println("00000", state)
-				}
+			if !client.spectator {
+				applyMovement(movement)
 			}
 			mu.Unlock()
 
 		case "player_attack":
-			log.Println("player attack: ", msg)
 			var attack PlayerAttack
 			data, err := json.Marshal(msg.Content)
 			if err != nil {
@@ -233,31 +301,68 @@ func handleClientStates(client *Client, clients map[*Client]bool, broadcast chan
 			}
 
 			// state.IsAttacking = true
+			attack.ID = client.Id
 			mu.Lock()
-			if state, exists := latestStates[attack.ID]; exists {
-				if time.Since(state.LastAttack).Seconds() >= float64(classMap[state.HeroClass].AttackSpeed)/1000.0 {
-					// Update player position based on movement
-					pos := Vec2D{
-						X: state.PosX,
-						Y: state.PosY,
-					}
-					dir := Vec2D{
-						X: attack.DirectionX,
-						Y: attack.DirectionY,
-					}
-					if classMap[state.HeroClass].AttackType == "magic" {
-						log.Println("magic attack:", attack.ID, pos, dir)
-						AddProjectile(attack.ID, pos, dir, classMap[state.HeroClass].AttackRange)
-					} else if classMap[state.HeroClass].AttackType == "physical" {
-						log.Println("melee attack:", attack.ID, pos, dir)
-						AddMelee(attack.ID, pos, classMap[state.HeroClass].AttackRange)
-					}
-
-					state.DirectionX = attack.DirectionX
-					state.DirectionY = attack.DirectionY
-					state.LastAttack = time.Now()
-					latestStates[attack.ID] = state
-				}
+			if !client.spectator {
+				applyAttack(attack)
+			}
+			mu.Unlock()
+		case "ping":
+			handlePing(client, msg)
+		case "player_ready":
+			var ready struct {
+				Ready bool `json:"ready"`
+			}
+			data, err := json.Marshal(msg.Content)
+			if err != nil {
+				log.Printf("Error marshaling ready: %v", err)
+				continue
+			}
+			if err := json.Unmarshal(data, &ready); err != nil {
+				log.Printf("Error unmarshaling ready: %v", err)
+				continue
+			}
+			mu.Lock()
+			if client.joined && client.ready != ready.Ready {
+				client.ready = ready.Ready
+				client.queuedAt = time.Now()
+				broadcastMatchState()
+			}
+			mu.Unlock()
+		case "player_ability":
+			cast, err := parseAbilityCast(msg.Content)
+			if err != nil {
+				log.Printf("Error reading ability data: %v", err)
+				continue
+			}
+			cast.ID = client.Id
+			mu.Lock()
+			if !client.spectator {
+				castAbility(cast)
+			}
+			mu.Unlock()
+		case "spectate":
+			mu.Lock()
+			if err := spectate(client); err != nil {
+				log.Println("Error starting spectator:", err)
+			}
+			mu.Unlock()
+		case "spectator_view":
+			mu.Lock()
+			if err := setSpectatorView(client, msg.Content); err != nil {
+				log.Printf("Error reading spectator view: %v", err)
+			}
+			mu.Unlock()
+		case "chat_send":
+			mu.Lock()
+			if err := handleChat(client, msg.Content); err != nil {
+				log.Printf("Error handling chat from client %d: %v", client.Id, err)
+			}
+			mu.Unlock()
+		case "queue_status":
+			mu.Lock()
+			if err := sendQueueStatus(client); err != nil {
+				log.Println("Error sending queue status:", err)
 			}
 			mu.Unlock()
 		case "new_player":
@@ -273,46 +378,8 @@ func handleClientStates(client *Client, clients map[*Client]bool, broadcast chan
 				return
 			}
 			mu.Lock()
-			for client := range clients {
-				if client.Id == msg.ClientID {
-					rand.Seed(uint64(time.Now().UnixNano()))
-					randomX := 20 + rand.Float64()*(winWidth-40)
-					randomY := 20 + rand.Float64()*(winHeight-40)
-					// Send welcome message
-					createMsg := Message{
-						Type: "new_player",
-						Content: map[string]interface{}{
-							"id": client.Id,
-							"X":  randomX,
-							"Y":  randomY,
-							// "heroClass": classMap[playerData.HeroClass].ID,
-							"HP": classMap[newPlayer.HeroClass].Health,
-						},
-					}
-					log.Println(createMsg)
-					var newPlayerState = PlayerState{
-						ID:        client.Id,
-						PosX:      randomX,
-						PosY:      randomY,
-						HeroClass: newPlayer.HeroClass,
-						Nickname:  newPlayer.Nickname,
-						Health:    classMap[newPlayer.HeroClass].Health,
-					}
-
-					latestStates[client.Id] = newPlayerState
-
-					jsonData, err := json.Marshal(createMsg)
-					if err != nil {
-						log.Println("JSON Marshal error:", err)
-						return
-					}
-					log.Println(createMsg)
-					// Отправляем данные по WebSocket
-					err = client.Conn.WriteMessage(websocket.TextMessage, jsonData)
-					if err != nil {
-						log.Println("WriteMessage error:", err)
-					}
-				}
+			if err := joinMatch(client, newPlayer); err != nil {
+				log.Println("WriteMessage error:", err)
 			}
 			mu.Unlock()
 
@@ -326,7 +393,7 @@ func handleClientStates(client *Client, clients map[*Client]bool, broadcast chan
 		}
 		client.Conn.Close()
 		delete(clients, client)
-		delete(latestStates, client.Id)
+		removePlayerState(client.Id)
 		log.Println("Client disconnected:", client.Id)
 		mu.Unlock()
 		// ticker.Stop()
@@ -334,6 +401,117 @@ func handleClientStates(client *Client, clients map[*Client]bool, broadcast chan
 
 }
 
+// joinMatch puts the client in the lobby with its chosen nickname and class.
+// During a match it respawns players who are in it, and queues anyone else to
+// be added by matchmaking. Must be called with mu held.
+func joinMatch(client *Client, profile PlayerData) error {
+	if _, alive := latestStates[client.Id]; alive {
+		log.Printf("Client %d sent new_player while alive, ignoring", client.Id)
+		return nil
+	}
+	if client.playing {
+		// Same class as before, the match rates them by it
+		if !canJoinMatch() {
+			return nil
+		}
+		return spawnPlayer(client)
+	}
+	client.profile = profile
+	client.joined = true
+	client.spectator = false
+	if canJoinMatch() && !client.ready {
+		client.ready = true
+		client.queuedAt = time.Now()
+	}
+	// Lets the client find itself in the lobby before it has spawned
+	if err := client.Conn.WriteJSON(Message{ClientID: client.Id, Type: "match_joined"}); err != nil {
+		return err
+	}
+	broadcastMatchState()
+	return nil
+}
+
+// spawnPlayer puts the client's player in the world and sends it the welcome
+// message. Must be called with mu held.
+func spawnPlayer(client *Client) error {
+	spawn := spawnPosition()
+	if client.team == 0 {
+		client.team = assignTeam()
+	}
+	state := PlayerState{
+		ID:        client.Id,
+		PosX:      spawn.X,
+		PosY:      spawn.Y,
+		HeroClass: client.profile.HeroClass,
+		Nickname:  client.profile.Nickname,
+		Health:    classMap[client.profile.HeroClass].Health,
+		Team:      client.team,
+	}
+	setPlayerState(state)
+	statsFor(state.ID, state.Nickname)
+	client.playing = true
+	createMsg := Message{
+		Type: "new_player",
+		Content: map[string]interface{}{
+			"id":          client.Id,
+			"X":           spawn.X,
+			"Y":           spawn.Y,
+			"HP":          state.Health,
+			"team":        state.Team,
+			"speed":       classMap[state.HeroClass].Speed,
+			"worldWidth":  worldWidth,
+			"worldHeight": worldHeight,
+		},
+	}
+	log.Println(createMsg)
+	if err := client.Conn.WriteJSON(createMsg); err != nil {
+		return err
+	}
+	return sendAbilityState(client)
+}
+
+// applyMovement moves the player by one movement step. Used for both clients
+// and bots. Must be called with mu held.
+func applyMovement(movement PlayerMovement) {
+	if state, exists := latestStates[movement.ID]; exists {
+		// Update player position based on movement
+		if 1 >= movement.MovingX && movement.MovingX >= -1 && 1 >= movement.MovingY && movement.MovingY >= -1 {
+			speed := float64(classMap[state.HeroClass].Speed) / 100 * statusSpeedScale(state.ID)
+			// Each axis moves on its own so players slide along walls
+			if (state.PosX-15) >= 0 && movement.MovingX == -1 || (state.PosX+15) <= worldWidth && movement.MovingX == 1 {
+				next := Vec2D{X: state.PosX + float64(movement.MovingX)*speed, Y: state.PosY}
+				if !arena.CircleBlocked(next, playerRadius) {
+					state.PosX = next.X
+				}
+			}
+			if (state.PosY-15) >= 0 && movement.MovingY == -1 || (state.PosY+15) <= worldHeight && movement.MovingY == 1 {
+				next := Vec2D{X: state.PosX, Y: state.PosY + float64(movement.MovingY)*speed}
+				if !arena.CircleBlocked(next, playerRadius) {
+					state.PosY = next.Y
+				}
+			}
+
+			state.DirectionX = movement.DirectionX
+			state.DirectionY = movement.DirectionY
+			state.LastInput = movement.Seq
+			setPlayerState(state)
+			// log.Println("00000", state)
+		}
+	}
+}
+
+// applyAttack fires the player's attack if it is off cooldown. Must be called
+// with mu held.
+func applyAttack(attack PlayerAttack) {
+	castAbility(AbilityCast{
+		ID:         attack.ID,
+		Input:      InputPrimary,
+		DirectionX: attack.DirectionX,
+		DirectionY: attack.DirectionY,
+		ViewTick:   attack.ViewTick,
+	})
+}
+
 func handleMessages(clients map[*Client]bool, broadcast chan Message, errChan chan error) {
 	for {
 		select {
@@ -345,7 +523,7 @@ func handleMessages(clients map[*Client]bool, broadcast chan Message, errChan ch
 					log.Printf("Error broadcasting to client %d: %v", client.Id, err)
 					client.Conn.Close()
 					delete(clients, client)
-					delete(latestStates, client.Id)
+					removePlayerState(client.Id)
 				}
 			}
 			mu.Unlock()
@@ -365,29 +543,43 @@ func broadcastLatestStates() {
 
 	for range ticker.C {
 		mu.Lock()
-		if len(latestStates) > 0 {
-			for client := range clients {
-				// log.Println("Broadcasting to client", latestStates)
-				msg := Message{
-					Type:    "states_update",
-					Content: latestStates,
-				}
-				log.Println(latestStates, client)
-				data, err := json.Marshal(latestStates)
-				if err != nil {
-					log.Println("Error marshaling state data: ", err)
-				}
-				log.Println(string(data))
-				if err := client.Conn.WriteJSON(msg); err != nil {
-					log.Printf("Error broadcasting to client %d: %v", client.Id, err)
-					client.Conn.Close()
-					delete(clients, client)
-					delete(latestStates, client.Id)
-				}
-
-				// log.Println("State update: ", msg)
-
+		serverTick++
+		recordHistory()
+		now := time.Now().UnixMilli()
+		for client := range clients {
+			if !watching(client) {
+				continue
 			}
+			// log.Println("Broadcasting to client", latestStates)
+			states, err := visiblePlayers(client)
+			if err != nil {
+				log.Printf("Error sending view events to client %d: %v", client.Id, err)
+				client.Conn.Close()
+				delete(clients, client)
+				removePlayerState(client.Id)
+				continue
+			}
+			msg := Message{
+				Type:    "states_update",
+				Tick:    serverTick,
+				Time:    now,
+				Content: states,
+			}
+			err = client.Conn.WriteJSON(msg)
+			if err == nil {
+				err = sendMonsters(client, now)
+			}
+			if err == nil && client.spectator && serverTick%spectateTargetsEvery == 0 {
+				err = sendSpectateTargets(client)
+			}
+			if err != nil {
+				log.Printf("Error broadcasting to client %d: %v", client.Id, err)
+				client.Conn.Close()
+				delete(clients, client)
+				removePlayerState(client.Id)
+			}
+
+			// log.Println("State update: ", msg)
 
 		}
 
@@ -397,88 +589,58 @@ func broadcastLatestStates() {
 func updateProjectiles() {
 	ticker := time.NewTicker(tickRate)
 	defer ticker.Stop()
-	var noRepeat bool
 	for range ticker.C {
 		projUpdate()
-		// Broadcast projectile states to all clients
+		// Send each client the projectiles in its area of interest
 		// states := GetProjectilesStates()
+		mu.Lock()
 		pmu.Lock()
 		//  log.Println("Projectiles len: ", projectiles)
+		now := time.Now().UnixMilli()
 
-		if len(projectiles) > 0 {
-			var projStates = make(map[int]ProjectileState)
-
-			for _, state := range projectiles {
+		for client := range clients {
+			if !watching(client) {
+				continue
+			}
+			_, left := client.projectilesInView.update(viewCenter(client), projectileGrid, projectilePosition)
+			// Once the last one is gone the client still needs an empty update
+			if len(client.projectilesInView) == 0 && len(left) == 0 {
+				continue
+			}
 
-				projStates[state.ID] = ProjectileState{
-					PosX: state.Pos.X,
-					PosY: state.Pos.Y,
+			var projStates = make(map[int]ProjectileState)
+			for id := range client.projectilesInView {
+				projStates[id] = ProjectileState{
+					PosX: projectiles[id].Pos.X,
+					PosY: projectiles[id].Pos.Y,
 				}
-
 			}
-			log.Println("Projectiles updated: ", projStates)
 
-			broadcast <- Message{
+			msg := Message{
 				Type:    "projectiles_update",
+				Tick:    serverTick,
+				Time:    now,
 				Content: projStates,
 			}
-			noRepeat = false
-		} else if !noRepeat {
-			noRepeat = true
-			broadcast <- Message{
-				Type:    "projectiles_update",
-				Content: make(map[int]ProjectileState),
+			if err := client.Conn.WriteJSON(msg); err != nil {
+				log.Printf("Error sending projectiles to client %d: %v", client.Id, err)
+				client.Conn.Close()
+				delete(clients, client)
+				removePlayerState(client.Id)
 			}
-
 		}
 		pmu.Unlock()
+		mu.Unlock()
 
 	}
 }
 
-func SendExplosion(ownerID int, circle Circle) {
-	broadcast <- Message{
+// SendExplosion damages everyone caught in the circle, using their positions
+// at the given tick. Must be called with mu held.
+func SendExplosion(ownerID int, circle Circle, tick uint64) []int {
+	sendInView(Vec2D{X: circle.X, Y: circle.Y}, Message{
 		Type:    "explosion_state",
 		Content: circle,
-	}
-	for playerID, player := range latestStates {
-
-		if player.ID == ownerID {
-			continue
-		}
-		playerCircle := Circle{
-			X:      player.PosX,
-			Y:      player.PosY,
-			Radius: 15,
-		}
-
-		if circle.Intersects(playerCircle) {
-			// Get owner's class for damage calculation
-			if owner, exists := latestStates[ownerID]; exists {
-				attackType := classMap[owner.HeroClass].AttackType
-				attack := classMap[owner.HeroClass].Attack
-				// Update player state
-				if attackType == "magic" {
-					attack = attack - (attack * classMap[latestStates[player.ID].HeroClass].MagicResistance)
-					player.Health -= attack
-				}
-
-				if player.Health <= 0 {
-					broadcast <- Message{
-						ClientID: player.ID,
-						Type:     "player_died",
-					}
-
-					delete(latestStates, playerID)
-					log.Printf("Player %d died", playerID)
-					break
-				}
-				latestStates[playerID] = player // Save updated state
-
-				log.Printf("Player %d hit by %s from player %d for %f damage",
-					playerID, attackType, ownerID, attack)
-			}
-		}
-	}
-
+	})
+	return damageArea(ownerID, fullCircle(circle), tick, DamageMagic)
 }
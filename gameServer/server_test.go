@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestArcIntersects(t *testing.T) {
+	// A 90° swing to the right with a range of 100
+	arc := Arc{Circle: Circle{Radius: 100}, Direction: 0, Width: math.Pi / 2}
+	// Where the upper edge of the swing ends
+	edge := Vec2D{X: 100 * math.Cos(math.Pi/4), Y: 100 * math.Sin(math.Pi/4)}
+
+	tests := []struct {
+		name   string
+		arc    Arc
+		target Circle
+		want   bool
+	}{
+		{name: "on the center line", arc: arc, target: Circle{X: 50, Radius: 10}, want: true},
+		{name: "touching the far end", arc: arc, target: Circle{X: 105, Radius: 10}, want: true},
+		{name: "out of range", arc: arc, target: Circle{X: 111, Radius: 10}, want: false},
+		{name: "on the caster", arc: arc, target: Circle{Radius: 10}, want: true},
+		{name: "behind, overlapping the caster", arc: arc, target: Circle{X: -5, Radius: 10}, want: true},
+		{name: "behind", arc: arc, target: Circle{X: -50, Radius: 10}, want: false},
+		{name: "center on the edge", arc: arc, target: Circle{X: 40, Y: 40, Radius: 10}, want: true},
+		{name: "just outside the edge", arc: arc, target: Circle{X: 35, Y: 47, Radius: 10}, want: true},
+		{name: "outside the edge", arc: arc, target: Circle{X: 20, Y: 60, Radius: 10}, want: false},
+		{
+			// Within reach of the range circle and the wedge's angle, but
+			// past the end of the edge
+			name:   "outside the edge near the range limit",
+			arc:    arc,
+			target: Circle{X: edge.X - 2, Y: edge.Y + 9, Radius: 8},
+			want:   false,
+		},
+		{name: "turned around", arc: Arc{Circle: Circle{Radius: 100}, Direction: math.Pi, Width: math.Pi / 2}, target: Circle{X: -50, Radius: 10}, want: true},
+		{name: "full circle", arc: fullCircle(Circle{Radius: 100}), target: Circle{X: -50, Y: -50, Radius: 10}, want: true},
+		{name: "full circle out of range", arc: fullCircle(Circle{Radius: 100}), target: Circle{X: -150, Radius: 10}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.arc.Intersects(tt.target); got != tt.want {
+				t.Errorf("Intersects(%+v) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+)
+
+// Size of a grid cell. Most queries are small circles, projectile hits,
+// explosions and melee swings, so cells around their size keep each query
+// to a handful of cells. Longer queries like the mage's range just cover more
+// cells.
+var gridCellSize = envFloat("GRID_CELL_SIZE", 64)
+
+// playerRadius is the collision radius of every player.
+const playerRadius = 15
+
+type cellKey struct {
+	X, Y int
+}
+
+// SpatialGrid is a uniform grid over the arena used for collision, area of
+// effect and proximity queries.
+type SpatialGrid struct {
+	cellSize float64
+	cells    map[cellKey]map[int]struct{}
+	entries  map[int]cellKey
+}
+
+func NewSpatialGrid(cellSize float64) *SpatialGrid {
+	return &SpatialGrid{
+		cellSize: cellSize,
+		cells:    make(map[cellKey]map[int]struct{}),
+		entries:  make(map[int]cellKey),
+	}
+}
+
+func (g *SpatialGrid) cellAt(pos Vec2D) cellKey {
+	return cellKey{
+		X: int(math.Floor(pos.X / g.cellSize)),
+		Y: int(math.Floor(pos.Y / g.cellSize)),
+	}
+}
+
+// Update inserts the entity or moves it to the cell of its new position.
+func (g *SpatialGrid) Update(id int, pos Vec2D) {
+	key := g.cellAt(pos)
+	if old, exists := g.entries[id]; exists {
+		if old == key {
+			return
+		}
+		g.removeFromCell(id, old)
+	}
+	cell, exists := g.cells[key]
+	if !exists {
+		cell = make(map[int]struct{})
+		g.cells[key] = cell
+	}
+	cell[id] = struct{}{}
+	g.entries[id] = key
+}
+
+func (g *SpatialGrid) Remove(id int) {
+	if key, exists := g.entries[id]; exists {
+		g.removeFromCell(id, key)
+		delete(g.entries, id)
+	}
+}
+
+func (g *SpatialGrid) removeFromCell(id int, key cellKey) {
+	cell := g.cells[key]
+	delete(cell, id)
+	if len(cell) == 0 {
+		delete(g.cells, key)
+	}
+}
+
+// QueryCircle returns the entities in every cell touched by the circle's
+// bounding box. Callers still do the exact intersection test.
+func (g *SpatialGrid) QueryCircle(c Circle) []int {
+	min := g.cellAt(Vec2D{X: c.X - c.Radius, Y: c.Y - c.Radius})
+	max := g.cellAt(Vec2D{X: c.X + c.Radius, Y: c.Y + c.Radius})
+
+	var ids []int
+	for x := min.X; x <= max.X; x++ {
+		for y := min.Y; y <= max.Y; y++ {
+			for id := range g.cells[cellKey{X: x, Y: y}] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+var (
+	playerGrid     = NewSpatialGrid(gridCellSize) // guarded by mu
+	projectileGrid = NewSpatialGrid(gridCellSize) // guarded by pmu
+)
+
+// setPlayerState stores the state and keeps the player grid in sync with it.
+// Must be called with mu held.
+func setPlayerState(state PlayerState) {
+	latestStates[state.ID] = state
+	playerGrid.Update(state.ID, Vec2D{X: state.PosX, Y: state.PosY})
+}
+
+// Must be called with mu held.
+func removePlayerState(id int) {
+	delete(latestStates, id)
+	playerGrid.Remove(id)
+	delete(abilityReady, id)
+	delete(statusEffects, id)
+}
+
+// rewindMargin is how far a player can have moved within the rewind window,
+// walking or with an ability, so grid queries against current positions still
+// find rewound targets.
+func rewindMargin() float64 {
+	var maxStep float64
+	for _, class := range classMap {
+		maxStep = math.Max(maxStep, float64(class.Speed)/100)
+	}
+	limit := rateLimits["player_moving"]
+	return maxStep*(limit.Burst+limit.Rate*maxRewind.Seconds()) + maxAbilityTravel()
+}
+
+// queryPlayers returns the players that may intersect the circle, including
+// targets that are only in range at a rewound tick. Must be called with mu held.
+func queryPlayers(c Circle) []int {
+	c.Radius += playerRadius + rewindMargin()
+	return playerGrid.QueryCircle(c)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sync"
+	"testing"
+
+	"golang.org/x/exp/rand"
+)
+
+var benchSizes = []int{10, 100, 1000}
+
+var drainOnce sync.Once
+
+// drainBroadcast throws broadcasts away, there's no handleMessages in tests
+// to send them.
+func drainBroadcast() {
+	drainOnce.Do(func() {
+		go func() {
+			for range broadcast {
+			}
+		}()
+	})
+}
+
+// Arena area per player in the benchmarks, about a 3000x2000 arena with 100
+// players. The arena grows with n so the density stays the same and only
+// the total count changes.
+const benchAreaPerPlayer = 60000.0
+
+// benchWorld spreads n players and n projectiles over a default arena sized
+// for n. The match stays in the lobby so nobody takes damage and the number
+// of players doesn't change between iterations.
+func benchWorld(b *testing.B, n int) map[int]ServerProjectile {
+	b.Helper()
+	log.SetOutput(io.Discard)
+	drainBroadcast()
+
+	r := rand.New(rand.NewSource(1))
+	side := math.Sqrt(benchAreaPerPlayer * float64(n))
+	width, height := worldWidth, worldHeight
+	b.Cleanup(func() { worldWidth, worldHeight = width, height })
+	arena = defaultArena(side*1.5, side/1.5)
+	worldWidth, worldHeight = arena.WorldSize()
+	latestStates = make(map[int]PlayerState)
+	playerGrid = NewSpatialGrid(gridCellSize)
+	projectileGrid = NewSpatialGrid(gridCellSize)
+	match.state = MatchLobby
+	serverTick = 0
+	for id := 1; id <= n; id++ {
+		pos := spawnPosition()
+		setPlayerState(PlayerState{ID: id, PosX: pos.X, PosY: pos.Y, HeroClass: MageClass.ID, Health: MageClass.Health})
+	}
+	recordHistory()
+
+	template := make(map[int]ServerProjectile, n)
+	for id := 0; id < n; id++ {
+		pos := spawnPosition()
+		template[id] = ServerProjectile{
+			ID:        id,
+			OwnerID:   1 + r.Intn(n),
+			Pos:       pos,
+			Direction: Vec2D{X: pos.X + r.Float64()*200 - 100, Y: pos.Y + r.Float64()*200 - 100},
+			Speed:     10,
+			MaxRange:  MageClass.AttackRange,
+		}
+	}
+	return template
+}
+
+func resetProjectiles(template map[int]ServerProjectile) {
+	projectiles = make(map[int]ServerProjectile, len(template))
+	projectileGrid = NewSpatialGrid(gridCellSize)
+	for id, proj := range template {
+		projectiles[id] = proj
+		projectileGrid.Update(id, proj.Pos)
+	}
+}
+
+// BenchmarkProjUpdate runs one projectile tick with n players and n
+// projectiles. ns/projectile should stay about the same as n grows.
+func BenchmarkProjUpdate(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("N=%d", n), func(b *testing.B) {
+			template := benchWorld(b, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				resetProjectiles(template)
+				b.StartTimer()
+				projUpdate()
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/projectile")
+		})
+	}
+}
+
+// BenchmarkQueryPlayers looks up the players around every projectile, the
+// query each projectile does once per tick.
+func BenchmarkQueryPlayers(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("N=%d", n), func(b *testing.B) {
+			template := benchWorld(b, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, proj := range template {
+					queryPlayers(Circle{X: proj.Pos.X, Y: proj.Pos.Y, Radius: 5})
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/query")
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// How often spectators get the full list of players they can follow
+const spectateTargetsEvery = 15 // ticks
+
+type SpectateTarget struct {
+	ID       int     `json:"id"`
+	Nickname string  `json:"nickname"`
+	Team     int     `json:"team"`
+	PosX     float64 `json:"posX"`
+	PosY     float64 `json:"posY"`
+}
+
+// spectate turns the connection into a spectator. It leaves the lobby and the
+// world, but keeps getting snapshots around its camera. Must be called with
+// mu held.
+func spectate(client *Client) error {
+	if _, alive := latestStates[client.Id]; alive {
+		removePlayerState(client.Id)
+		broadcast <- Message{ClientID: client.Id, Type: "player_left"}
+	}
+	client.spectator = true
+	client.joined, client.ready, client.playing = false, false, false
+	client.team = 0
+	broadcastMatchState()
+	return client.Conn.WriteJSON(Message{ClientID: client.Id, Type: "spectating"})
+}
+
+// setSpectatorView moves a spectator's camera, which is the center of its
+// area of interest. Must be called with mu held.
+func setSpectatorView(client *Client, content interface{}) error {
+	var view Vec2D
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &view); err != nil {
+		return err
+	}
+	if client.spectator {
+		client.view = view
+	}
+	return nil
+}
+
+// sendSpectateTargets lists every player in the match, including the ones
+// outside the spectator's area of interest. Must be called with mu held.
+func sendSpectateTargets(client *Client) error {
+	targets := make([]SpectateTarget, 0, len(latestStates))
+	for _, state := range latestStates {
+		targets = append(targets, SpectateTarget{
+			ID:       state.ID,
+			Nickname: state.Nickname,
+			Team:     state.Team,
+			PosX:     state.PosX,
+			PosY:     state.PosY,
+		})
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ID < targets[j].ID })
+	return client.Conn.WriteJSON(Message{Type: "spectate_targets", Content: targets})
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// Status effect kinds
+const (
+	StatusSlow   = "slow"   // Magnitude is the fraction of speed lost
+	StatusStun   = "stun"   // can't move or cast
+	StatusBurn   = "burn"   // Magnitude is damage per second for each stack
+	StatusShield = "shield" // Magnitude is the damage left to absorb
+)
+
+// What happens when an effect is applied to someone who already has it
+const (
+	StackRefresh = iota // keep the stronger magnitude and the longer duration
+	StackAdd            // add a stack up to MaxStacks and restart the duration
+	StackExtend         // add the new duration to what's left
+)
+
+type StatusKind struct {
+	Stack     int
+	MaxStacks int
+	TickEvery time.Duration
+	// OnTick runs every TickEvery while the effect lasts. Returns true if the
+	// player died.
+	OnTick func(player PlayerState, effect *StatusEffect) bool
+}
+
+// Burn damage is dealt in steps this far apart
+const burnTickEvery = 500 * time.Millisecond
+
+var statusKinds = map[string]StatusKind{
+	StatusSlow:   {Stack: StackRefresh},
+	StatusStun:   {Stack: StackRefresh},
+	StatusBurn:   {Stack: StackAdd, MaxStacks: 3, TickEvery: burnTickEvery, OnTick: burnTick},
+	StatusShield: {Stack: StackRefresh},
+}
+
+type StatusEffect struct {
+	Kind      string  `json:"kind"`
+	SourceID  int     `json:"source"`
+	Magnitude float64 `json:"magnitude"`
+	Stacks    int     `json:"stacks"`
+	Remaining float64 `json:"remaining"` // seconds, filled in for snapshots
+	expires   time.Time
+	nextTick  time.Time
+}
+
+// StatusApply describes an effect an ability, hazard or item puts on a player.
+type StatusApply struct {
+	Kind      string
+	Magnitude float64
+	Duration  time.Duration
+}
+
+// Active effects by player. Guarded by mu.
+var statusEffects = make(map[int][]*StatusEffect)
+
+func runStatuses() {
+	ticker := time.NewTicker(tickRate)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		mu.Lock()
+		if match.state == MatchPlaying {
+			updateStatuses(now)
+		}
+		mu.Unlock()
+	}
+}
+
+// applyStatus puts the effect on the player, following its kind's stacking
+// rule. Must be called with mu held.
+func applyStatus(targetID, sourceID int, apply StatusApply) {
+	kind, known := statusKinds[apply.Kind]
+	if _, alive := latestStates[targetID]; !alive || !known {
+		return
+	}
+	now := time.Now()
+	expires := now.Add(apply.Duration)
+	for _, effect := range statusEffects[targetID] {
+		if effect.Kind != apply.Kind {
+			continue
+		}
+		effect.SourceID = sourceID
+		switch kind.Stack {
+		case StackRefresh:
+			effect.Magnitude = math.Max(effect.Magnitude, apply.Magnitude)
+			if expires.After(effect.expires) {
+				effect.expires = expires
+			}
+		case StackAdd:
+			effect.Stacks = min(effect.Stacks+1, kind.MaxStacks)
+			effect.Magnitude = math.Max(effect.Magnitude, apply.Magnitude)
+			effect.expires = expires
+		case StackExtend:
+			effect.expires = effect.expires.Add(apply.Duration)
+		}
+		return
+	}
+	statusEffects[targetID] = append(statusEffects[targetID], &StatusEffect{
+		Kind:      apply.Kind,
+		SourceID:  sourceID,
+		Magnitude: apply.Magnitude,
+		Stacks:    1,
+		expires:   expires,
+		nextTick:  now.Add(kind.TickEvery),
+	})
+	log.Printf("Player %d got %s from %d", targetID, apply.Kind, sourceID)
+}
+
+// updateStatuses runs tick callbacks and drops expired effects. Must be
+// called with mu held.
+func updateStatuses(now time.Time) {
+	for id, effects := range statusEffects {
+		remaining := effects[:0]
+		for _, effect := range effects {
+			kind := statusKinds[effect.Kind]
+			for kind.OnTick != nil && !now.Before(effect.nextTick) && effect.nextTick.Before(effect.expires) {
+				effect.nextTick = effect.nextTick.Add(kind.TickEvery)
+				if kind.OnTick(latestStates[id], effect) {
+					break
+				}
+			}
+			if now.Before(effect.expires) {
+				remaining = append(remaining, effect)
+			}
+		}
+		if _, alive := latestStates[id]; !alive {
+			continue // removePlayerState already dropped their effects
+		}
+		if len(remaining) == 0 {
+			delete(statusEffects, id)
+		} else {
+			statusEffects[id] = remaining
+		}
+	}
+}
+
+// burnTick deals one tick of burn damage as magic from whoever applied it.
+func burnTick(player PlayerState, effect *StatusEffect) bool {
+	damage := effect.Magnitude * float64(effect.Stacks) * burnTickEvery.Seconds()
+	return dealDamage(effect.SourceID, player, damage, DamageMagic)
+}
+
+// Must be called with mu held.
+func statusOf(id int, kind string) *StatusEffect {
+	for _, effect := range statusEffects[id] {
+		if effect.Kind == kind {
+			return effect
+		}
+	}
+	return nil
+}
+
+// statusSpeedScale is what the player's movement speed is multiplied by.
+// Must be called with mu held.
+func statusSpeedScale(id int) float64 {
+	if statusOf(id, StatusStun) != nil {
+		return 0
+	}
+	if slow := statusOf(id, StatusSlow); slow != nil {
+		return math.Max(0, 1-slow.Magnitude)
+	}
+	return 1
+}
+
+// canCast reports whether the player's abilities aren't locked out. Must be
+// called with mu held.
+func canCast(id int) bool {
+	return statusOf(id, StatusStun) == nil
+}
+
+// absorbDamage takes damage out of the player's shield and returns what's
+// left to hit their health. Must be called with mu held.
+func absorbDamage(id int, damage float64) float64 {
+	shield := statusOf(id, StatusShield)
+	if shield == nil {
+		return damage
+	}
+	absorbed := math.Min(shield.Magnitude, damage)
+	shield.Magnitude -= absorbed
+	if shield.Magnitude <= 0 {
+		shield.expires = time.Now() // broken, dropped on the next update
+	}
+	return damage - absorbed
+}
+
+// activeStatuses copies the player's effects for a snapshot. Must be called
+// with mu held.
+func activeStatuses(id int, now time.Time) []StatusEffect {
+	var active []StatusEffect
+	for _, effect := range statusEffects[id] {
+		if !now.Before(effect.expires) {
+			continue
+		}
+		status := *effect
+		status.Remaining = effect.expires.Sub(now).Seconds()
+		active = append(active, status)
+	}
+	return active
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"io"
+	"log"
+	"math"
+	"testing"
+	"time"
+)
+
+// withPlayers swaps in a running match with only these players, and nobody
+// connected, for the length of the test.
+func withPlayers(t *testing.T, states ...PlayerState) {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	drainBroadcast()
+	oldStates, oldGrid, oldEffects, oldClients := latestStates, playerGrid, statusEffects, clients
+	oldMatch, oldStats := match.state, match.stats
+	t.Cleanup(func() {
+		latestStates, playerGrid, statusEffects, clients = oldStates, oldGrid, oldEffects, oldClients
+		match.state, match.stats = oldMatch, oldStats
+	})
+	latestStates = make(map[int]PlayerState)
+	playerGrid = NewSpatialGrid(gridCellSize)
+	statusEffects = make(map[int][]*StatusEffect)
+	clients = make(map[*Client]bool)
+	match.state = MatchPlaying
+	match.stats = make(map[int]*PlayerStats)
+	for _, state := range states {
+		setPlayerState(state)
+	}
+}
+
+func mage(id int) PlayerState {
+	return PlayerState{ID: id, HeroClass: MageClass.ID, Health: MageClass.Health}
+}
+
+func TestApplyStatus(t *testing.T) {
+	statusKinds["extend"] = StatusKind{Stack: StackExtend}
+	defer delete(statusKinds, "extend")
+
+	tests := []struct {
+		name      string
+		applies   []StatusApply
+		kind      string
+		magnitude float64
+		stacks    int
+		remaining time.Duration // 0 when there should be no effect
+	}{
+		{
+			name: "refresh keeps the stronger magnitude",
+			applies: []StatusApply{
+				{Kind: StatusSlow, Magnitude: 0.3, Duration: 2 * time.Second},
+				{Kind: StatusSlow, Magnitude: 0.5, Duration: time.Second},
+			},
+			kind: StatusSlow, magnitude: 0.5, stacks: 1, remaining: 2 * time.Second,
+		},
+		{
+			name: "refresh keeps the longer duration",
+			applies: []StatusApply{
+				{Kind: StatusSlow, Magnitude: 0.5, Duration: time.Second},
+				{Kind: StatusSlow, Magnitude: 0.3, Duration: 3 * time.Second},
+			},
+			kind: StatusSlow, magnitude: 0.5, stacks: 1, remaining: 3 * time.Second,
+		},
+		{
+			name: "stacks restart the duration",
+			applies: []StatusApply{
+				{Kind: StatusBurn, Magnitude: 6, Duration: 3 * time.Second},
+				{Kind: StatusBurn, Magnitude: 4, Duration: time.Second},
+			},
+			kind: StatusBurn, magnitude: 6, stacks: 2, remaining: time.Second,
+		},
+		{
+			name: "stacks stop at the limit",
+			applies: []StatusApply{
+				{Kind: StatusBurn, Magnitude: 6, Duration: time.Second},
+				{Kind: StatusBurn, Magnitude: 6, Duration: time.Second},
+				{Kind: StatusBurn, Magnitude: 6, Duration: time.Second},
+				{Kind: StatusBurn, Magnitude: 6, Duration: time.Second},
+			},
+			kind: StatusBurn, magnitude: 6, stacks: 3, remaining: time.Second,
+		},
+		{
+			name: "extend adds the durations",
+			applies: []StatusApply{
+				{Kind: "extend", Magnitude: 1, Duration: 2 * time.Second},
+				{Kind: "extend", Magnitude: 1, Duration: time.Second},
+			},
+			kind: "extend", magnitude: 1, stacks: 1, remaining: 3 * time.Second,
+		},
+		{
+			name:    "unknown kind",
+			applies: []StatusApply{{Kind: "frozen", Magnitude: 1, Duration: time.Second}},
+			kind:    "frozen",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPlayers(t, mage(1))
+			for _, apply := range tt.applies {
+				applyStatus(1, 2, apply)
+			}
+			active := activeStatuses(1, time.Now())
+			if tt.remaining == 0 {
+				if len(active) != 0 {
+					t.Fatalf("got effects %+v, want none", active)
+				}
+				return
+			}
+			if len(active) != 1 {
+				t.Fatalf("got effects %+v, want one", active)
+			}
+			effect := active[0]
+			if effect.Kind != tt.kind || effect.Magnitude != tt.magnitude || effect.Stacks != tt.stacks || effect.SourceID != 2 {
+				t.Errorf("got %+v, want %s with magnitude %v and %d stacks", effect, tt.kind, tt.magnitude, tt.stacks)
+			}
+			if math.Abs(effect.Remaining-tt.remaining.Seconds()) > 0.1 {
+				t.Errorf("%vs remaining, want %v", effect.Remaining, tt.remaining)
+			}
+		})
+	}
+
+	t.Run("dead target", func(t *testing.T) {
+		withPlayers(t)
+		applyStatus(1, 2, StatusApply{Kind: StatusSlow, Magnitude: 0.5, Duration: time.Second})
+		if len(statusEffects) != 0 {
+			t.Errorf("dead player got %v", statusEffects[1])
+		}
+	})
+}
+
+func TestStatusSpeedScale(t *testing.T) {
+	tests := []struct {
+		name    string
+		applies []StatusApply
+		want    float64
+	}{
+		{name: "no effects", want: 1},
+		{name: "slowed", applies: []StatusApply{{Kind: StatusSlow, Magnitude: 0.4, Duration: time.Second}}, want: 0.6},
+		{name: "slowed past a stop", applies: []StatusApply{{Kind: StatusSlow, Magnitude: 1.5, Duration: time.Second}}, want: 0},
+		{name: "stunned", applies: []StatusApply{{Kind: StatusStun, Duration: time.Second}}, want: 0},
+		{
+			name: "stun beats slow",
+			applies: []StatusApply{
+				{Kind: StatusSlow, Magnitude: 0.2, Duration: time.Second},
+				{Kind: StatusStun, Duration: time.Second},
+			},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPlayers(t, mage(1))
+			for _, apply := range tt.applies {
+				applyStatus(1, 2, apply)
+			}
+			if got := statusSpeedScale(1); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("speed scale %v, want %v", got, tt.want)
+			}
+			stunned := statusOf(1, StatusStun) != nil
+			if canCast(1) == stunned {
+				t.Errorf("canCast %v while stunned %v", canCast(1), stunned)
+			}
+		})
+	}
+}
+
+func TestAbsorbDamage(t *testing.T) {
+	withPlayers(t, mage(1))
+	if left := absorbDamage(1, 30); left != 30 {
+		t.Errorf("no shield let %v through, want 30", left)
+	}
+
+	applyStatus(1, 1, StatusApply{Kind: StatusShield, Magnitude: 50, Duration: time.Second})
+	if left := absorbDamage(1, 30); left != 0 {
+		t.Errorf("shield let %v through, want 0", left)
+	}
+	if left := absorbDamage(1, 30); left != 10 {
+		t.Errorf("shield with 20 left let %v through, want 10", left)
+	}
+	if active := activeStatuses(1, time.Now()); len(active) != 0 {
+		t.Errorf("broken shield still active: %+v", active)
+	}
+}
+
+func TestBurnTicks(t *testing.T) {
+	withPlayers(t, mage(1))
+	applyStatus(1, 0, StatusApply{Kind: StatusBurn, Magnitude: 6, Duration: 3 * time.Second})
+	applyStatus(1, 0, StatusApply{Kind: StatusBurn, Magnitude: 6, Duration: 3 * time.Second})
+	burn := statusEffects[1][0]
+	start := burn.nextTick.Add(-burnTickEvery)
+	burn.expires = start.Add(3 * time.Second)
+
+	// Two ticks of 2 stacks, as magic damage
+	updateStatuses(start.Add(time.Second))
+	tick := mitigate(6*2*burnTickEvery.Seconds(), MageClass.MagicResistance, 0)
+	if want := MageClass.Health - 2*tick; math.Abs(latestStates[1].Health-want) > 1e-9 {
+		t.Errorf("health %v after a second, want %v", latestStates[1].Health, want)
+	}
+
+	// Burns out, the tick at expiry doesn't count
+	updateStatuses(start.Add(10 * time.Second))
+	if want := MageClass.Health - 5*tick; math.Abs(latestStates[1].Health-want) > 1e-9 {
+		t.Errorf("health %v after burning out, want %v", latestStates[1].Health, want)
+	}
+	if len(statusEffects[1]) != 0 {
+		t.Errorf("burn still active: %+v", statusEffects[1])
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+const (
+	FriendlyFireOff     = "off"
+	FriendlyFireOn      = "on"
+	FriendlyFireReduced = "reduced"
+)
+
+// Team deathmatch settings
+var (
+	teamCount         = envInt("TEAM_COUNT", 2)
+	friendlyFire      = envString("FRIENDLY_FIRE", FriendlyFireOff)
+	friendlyFireScale = envFloat("FRIENDLY_FIRE_SCALE", 0.5) // damage multiplier when reduced
+	teamScoreLimit    = envInt("TEAM_SCORE_LIMIT", 20)
+)
+
+// Kills per team, indexed by team number - 1. Guarded by mu.
+var teamScores []int
+
+// initTeams checks the team settings and sets up the scores. Call it before
+// the match starts.
+func initTeams() error {
+	if teamCount < 2 {
+		return fmt.Errorf("TEAM_COUNT must be at least 2, got %d", teamCount)
+	}
+	teamScores = make([]int, teamCount)
+	return nil
+}
+
+// assignTeam picks the team with the fewest players so teams stay balanced
+// as people join. Everyone in the match counts, dead or alive. Returns 0
+// outside of team modes. Must be called with mu held.
+func assignTeam() int {
+	if !teamMode() {
+		return 0
+	}
+	counts := make([]int, teamCount)
+	for client := range clients {
+		if client.team > 0 {
+			counts[client.team-1]++
+		}
+	}
+	for _, bot := range bots {
+		if bot.team > 0 {
+			counts[bot.team-1]++
+		}
+	}
+	best := 0
+	for i := range counts {
+		if counts[i] < counts[best] {
+			best = i
+		}
+	}
+	return best + 1
+}
+
+func teamMode() bool {
+	return gameMode == ModeTDM || gameMode == ModeCTF ||
+		(gameMode == ModeKOTH && kothScoring == KothScoreTeam)
+}
+
+// scoreLimit is the team score that wins a round in the current mode.
+func scoreLimit() int {
+	if gameMode == ModeCTF {
+		return ctfCaptureLimit
+	}
+	return teamScoreLimit
+}
+
+// sameTeam reports whether both players are on the same team. Must be called
+// with mu held.
+func sameTeam(a, b int) bool {
+	teamA := latestStates[a].Team
+	return teamA != 0 && teamA == latestStates[b].Team
+}
+
+// damageScale is the multiplier for damage from ownerID to targetID, which
+// is only less than one for teammates with reduced friendly fire. Must be
+// called with mu held.
+func damageScale(ownerID, targetID int) float64 {
+	if friendlyFire == FriendlyFireReduced && sameTeam(ownerID, targetID) {
+		return friendlyFireScale
+	}
+	return 1
+}
+
+// scoreKill credits the killer's team when they kill someone from another
+// team, and ends the round once a team reaches the score limit. Must be
+// called with mu held, before the victim's state is removed.
+func scoreKill(killerID, victimID int) {
+	if gameMode != ModeTDM || sameTeam(killerID, victimID) {
+		return
+	}
+	team := latestStates[killerID].Team
+	if team == 0 {
+		return
+	}
+	addTeamScore(team)
+}
+
+// addTeamScore gives a point to the team and ends the round once it reaches
+// the score limit. Must be called with mu held.
+func addTeamScore(team int) {
+	teamScores[team-1]++
+	broadcastTeamScores()
+
+	if teamScores[team-1] >= scoreLimit() {
+		log.Printf("Team %d won with a score of %d", team, teamScores[team-1])
+		broadcast <- Message{
+			Type:    "team_won",
+			Content: map[string]interface{}{"team": team, "scores": teamScores},
+		}
+		// Ratings go by the final scores, so only reset them after
+		endMatch(fmt.Sprintf("Team %d wins", team))
+		teamScores = make([]int, teamCount)
+		broadcastTeamScores()
+	}
+}
+
+// teamStandings is each team's score in the current mode, by team number.
+// Must be called with mu held.
+func teamStandings() map[int]float64 {
+	standings := make(map[int]float64)
+	switch gameMode {
+	case ModeTDM, ModeCTF:
+		for i, score := range teamScores {
+			standings[i+1] = float64(score)
+		}
+	case ModeKOTH:
+		for side, score := range kothScores {
+			standings[side] = score
+		}
+	}
+	return standings
+}
+
+func teamScoreMessage() Message {
+	return Message{
+		Type:    "team_score",
+		Content: map[string]interface{}{"scores": teamScores, "limit": scoreLimit()},
+	}
+}
+
+// Must be called with mu held.
+func broadcastTeamScores() {
+	broadcast <- teamScoreMessage()
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+
+	"golang.org/x/exp/rand"
+)
+
+const (
+	TileWalkable   = 0
+	TileSolid      = 1 // blocks players and projectiles
+	TileDecorative = 2 // drawn, but walkable
+)
+
+// TileMap is the arena layout. Tiles are stored row by row starting from the
+// bottom row, so tile (x, y) covers world positions
+// [x*TileSize, (x+1)*TileSize) the same way the client draws them.
+type TileMap struct {
+	Width    int     `json:"width"`  // in tiles
+	Height   int     `json:"height"` // in tiles
+	TileSize float64 `json:"tileSize"`
+	Tiles    []int   `json:"tiles"`
+	Spawns   []Vec2D `json:"spawns"`
+
+	// Placed in the map editor for game modes to use
+	Pickups    []Vec2D `json:"pickups,omitempty"`
+	Objectives []Vec2D `json:"objectives,omitempty"`
+}
+
+var arena *TileMap
+
+// Tile size of the default arena. The world needs to be at least
+// minArenaTiles across for the walls and some room inside them.
+const (
+	defaultTileSize = 40.0
+	minArenaTiles   = 5
+)
+
+// loadArena reads the map from MAP_FILE, or builds the default arena when it
+// isn't set. The world size always follows the map.
+func loadArena() (*TileMap, error) {
+	path := os.Getenv("MAP_FILE")
+	if path == "" {
+		if worldWidth < minArenaTiles*defaultTileSize || worldHeight < minArenaTiles*defaultTileSize {
+			return nil, fmt.Errorf("world %vx%v is too small for the default arena", worldWidth, worldHeight)
+		}
+		return defaultArena(worldWidth, worldHeight), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading map: %v", err)
+	}
+	return parseTileMap(data)
+}
+
+func parseTileMap(data []byte) (*TileMap, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("parsing map: %v", err)
+	}
+	if _, isTiled := probe["layers"]; isTiled {
+		return parseTiledMap(data)
+	}
+
+	var m TileMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing map: %v", err)
+	}
+	if m.Width <= 0 || m.Height <= 0 || m.TileSize <= 0 || len(m.Tiles) != m.Width*m.Height {
+		return nil, fmt.Errorf("invalid map: %dx%d tiles of size %v with %d entries", m.Width, m.Height, m.TileSize, len(m.Tiles))
+	}
+	return &m, nil
+}
+
+// Subset of the Tiled JSON export we understand: tile layers named after
+// "wall"/"solid", "decor" or "floor"/"ground", and spawn, pickup and objective
+// objects in object groups. Anything else is rejected rather than silently
+// dropped, so a map doesn't load with missing walls.
+type tiledMap struct {
+	Width      int          `json:"width"`
+	Height     int          `json:"height"`
+	TileWidth  float64      `json:"tilewidth"`
+	TileHeight float64      `json:"tileheight"`
+	Layers     []tiledLayer `json:"layers"`
+}
+
+type tiledLayer struct {
+	Name    string        `json:"name"`
+	Type    string        `json:"type"`
+	Data    []int         `json:"data"`
+	Objects []tiledObject `json:"objects"`
+}
+
+type tiledObject struct {
+	Name  string  `json:"name"`
+	Type  string  `json:"type"`
+	Class string  `json:"class"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+}
+
+func parseTiledMap(data []byte) (*TileMap, error) {
+	var tm tiledMap
+	if err := json.Unmarshal(data, &tm); err != nil {
+		return nil, fmt.Errorf("parsing Tiled map: %v", err)
+	}
+	if tm.Width <= 0 || tm.Height <= 0 || tm.TileWidth <= 0 || tm.TileHeight <= 0 {
+		return nil, fmt.Errorf("invalid Tiled map: %dx%d tiles of size %vx%v", tm.Width, tm.Height, tm.TileWidth, tm.TileHeight)
+	}
+	if tm.TileWidth != tm.TileHeight {
+		return nil, fmt.Errorf("invalid Tiled map: tiles must be square, got %vx%v", tm.TileWidth, tm.TileHeight)
+	}
+
+	m := &TileMap{
+		Width:    tm.Width,
+		Height:   tm.Height,
+		TileSize: tm.TileWidth,
+		Tiles:    make([]int, tm.Width*tm.Height),
+	}
+	worldH := float64(tm.Height) * tm.TileHeight
+	for _, layer := range tm.Layers {
+		name := strings.ToLower(layer.Name)
+		switch layer.Type {
+		case "tilelayer":
+			var kind int
+			switch {
+			case strings.Contains(name, "wall") || strings.Contains(name, "solid"):
+				kind = TileSolid
+			case strings.Contains(name, "decor"):
+				kind = TileDecorative
+			case strings.Contains(name, "floor") || strings.Contains(name, "ground"):
+				continue // walkable already
+			default:
+				return nil, fmt.Errorf("unknown Tiled tile layer %q", layer.Name)
+			}
+			if len(layer.Data) != len(m.Tiles) {
+				// Infinite maps store chunks instead of data
+				return nil, fmt.Errorf("invalid Tiled layer %q: %d tiles, expected %d", layer.Name, len(layer.Data), len(m.Tiles))
+			}
+			for i, gid := range layer.Data {
+				if gid == 0 {
+					continue
+				}
+				// Tiled rows go top down, ours bottom up
+				x, y := i%tm.Width, tm.Height-1-i/tm.Width
+				if m.Tiles[y*m.Width+x] != TileSolid {
+					m.Tiles[y*m.Width+x] = kind
+				}
+			}
+		case "objectgroup":
+			for _, obj := range layer.Objects {
+				pos := Vec2D{X: obj.X, Y: worldH - obj.Y}
+				switch tiledObjectKind(obj) {
+				case "spawn":
+					m.Spawns = append(m.Spawns, pos)
+				case "pickup":
+					m.Pickups = append(m.Pickups, pos)
+				case "objective":
+					m.Objectives = append(m.Objectives, pos)
+				default:
+					return nil, fmt.Errorf("unknown Tiled object %q in layer %q", tiledObjectKind(obj), layer.Name)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unsupported Tiled layer %q of type %q", layer.Name, layer.Type)
+		}
+	}
+	return m, nil
+}
+
+// Tiled keeps the object kind in "type" (older exports), "class" or the name.
+func tiledObjectKind(obj tiledObject) string {
+	for _, kind := range []string{obj.Type, obj.Class, obj.Name} {
+		if kind != "" {
+			return strings.ToLower(kind)
+		}
+	}
+	return ""
+}
+
+// defaultArena is a walled rectangle with a few pillars for cover. The size
+// has to be at least minArenaTiles tiles across.
+func defaultArena(width, height float64) *TileMap {
+	m := &TileMap{
+		Width:    int(width / defaultTileSize),
+		Height:   int(height / defaultTileSize),
+		TileSize: defaultTileSize,
+	}
+	m.Tiles = make([]int, m.Width*m.Height)
+	for x := 0; x < m.Width; x++ {
+		m.Tiles[x] = TileSolid
+		m.Tiles[(m.Height-1)*m.Width+x] = TileSolid
+	}
+	for y := 0; y < m.Height; y++ {
+		m.Tiles[y*m.Width] = TileSolid
+		m.Tiles[y*m.Width+m.Width-1] = TileSolid
+	}
+	// Pillars are 2x2 with a decoration at the bottom left, so keep them
+	// clear of the walls
+	stepX, stepY := max(m.Width/5, 2), max(m.Height/4, 2)
+	for px := stepX; px < m.Width-2; px += stepX {
+		for py := stepY; py < m.Height-2; py += stepY {
+			m.Tiles[py*m.Width+px] = TileSolid
+			m.Tiles[py*m.Width+px+1] = TileSolid
+			m.Tiles[(py+1)*m.Width+px] = TileSolid
+			m.Tiles[(py+1)*m.Width+px+1] = TileSolid
+			m.Tiles[(py-1)*m.Width+px-1] = TileDecorative
+		}
+	}
+	return m
+}
+
+func (m *TileMap) WorldSize() (float64, float64) {
+	return float64(m.Width) * m.TileSize, float64(m.Height) * m.TileSize
+}
+
+// TileAt returns the tile under a world position. Everything outside the map
+// counts as solid.
+func (m *TileMap) TileAt(x, y float64) int {
+	tx := int(math.Floor(x / m.TileSize))
+	ty := int(math.Floor(y / m.TileSize))
+	if tx < 0 || ty < 0 || tx >= m.Width || ty >= m.Height {
+		return TileSolid
+	}
+	return m.Tiles[ty*m.Width+tx]
+}
+
+// CircleBlocked reports whether a circle overlaps any solid tile.
+func (m *TileMap) CircleBlocked(pos Vec2D, radius float64) bool {
+	minX := int(math.Floor((pos.X - radius) / m.TileSize))
+	maxX := int(math.Floor((pos.X + radius) / m.TileSize))
+	minY := int(math.Floor((pos.Y - radius) / m.TileSize))
+	maxY := int(math.Floor((pos.Y + radius) / m.TileSize))
+	for tx := minX; tx <= maxX; tx++ {
+		for ty := minY; ty <= maxY; ty++ {
+			cx, cy := (float64(tx)+0.5)*m.TileSize, (float64(ty)+0.5)*m.TileSize
+			if m.TileAt(cx, cy) != TileSolid {
+				continue
+			}
+			// Closest point of the tile to the circle center
+			nearX := math.Max(float64(tx)*m.TileSize, math.Min(pos.X, float64(tx+1)*m.TileSize))
+			nearY := math.Max(float64(ty)*m.TileSize, math.Min(pos.Y, float64(ty+1)*m.TileSize))
+			if math.Hypot(pos.X-nearX, pos.Y-nearY) < radius {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// spawnPosition picks one of the map's spawn points, or a random free spot if
+// the map doesn't define any.
+func spawnPosition() Vec2D {
+	if len(arena.Spawns) > 0 {
+		return arena.Spawns[rand.Intn(len(arena.Spawns))]
+	}
+	for i := 0; i < 100; i++ {
+		pos := Vec2D{
+			X: 20 + rand.Float64()*(worldWidth-40),
+			Y: 20 + rand.Float64()*(worldHeight-40),
+		}
+		if !arena.CircleBlocked(pos, playerRadius) {
+			return pos
+		}
+	}
+	log.Println("No free spawn position found")
+	return Vec2D{X: worldWidth / 2, Y: worldHeight / 2}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTileMap(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		err   string // part of the error, when parsing should fail
+		check func(t *testing.T, m *TileMap)
+	}{
+		{
+			name: "native",
+			data: `{"width": 2, "height": 2, "tileSize": 32, "tiles": [1, 0, 0, 2], "spawns": [{"X": 48, "Y": 16}]}`,
+			check: func(t *testing.T, m *TileMap) {
+				if m.TileAt(0, 0) != TileSolid || m.TileAt(40, 40) != TileDecorative {
+					t.Errorf("tiles %v", m.Tiles)
+				}
+				if len(m.Spawns) != 1 || m.Spawns[0] != (Vec2D{X: 48, Y: 16}) {
+					t.Errorf("spawns %v", m.Spawns)
+				}
+			},
+		},
+		{
+			name: "native with missing tiles",
+			data: `{"width": 2, "height": 2, "tileSize": 32, "tiles": [1, 0, 0]}`,
+			err:  "invalid map",
+		},
+		{
+			name: "not json",
+			data: `walls everywhere`,
+			err:  "parsing map",
+		},
+		{
+			// Tiled rows go top down, so the top left wall ends up in our
+			// top row and the spawn's y is flipped
+			name: "tiled",
+			data: `{"width": 2, "height": 2, "tilewidth": 32, "tileheight": 32, "layers": [
+				{"name": "Floor", "type": "tilelayer", "data": [3, 3, 3, 3]},
+				{"name": "Walls", "type": "tilelayer", "data": [5, 0, 0, 0]},
+				{"name": "Decoration", "type": "tilelayer", "data": [4, 0, 0, 4]},
+				{"name": "Markers", "type": "objectgroup", "objects": [
+					{"type": "spawn", "x": 10, "y": 20},
+					{"class": "Pickup", "x": 30, "y": 40},
+					{"name": "objective", "x": 50, "y": 60}
+				]}
+			]}`,
+			check: func(t *testing.T, m *TileMap) {
+				if m.TileSize != 32 || m.Width != 2 || m.Height != 2 {
+					t.Fatalf("got %dx%d tiles of %v", m.Width, m.Height, m.TileSize)
+				}
+				// Walls win over decorations on the same tile
+				want := []int{TileWalkable, TileDecorative, TileSolid, TileWalkable}
+				for i := range want {
+					if m.Tiles[i] != want[i] {
+						t.Fatalf("tiles %v, want %v", m.Tiles, want)
+					}
+				}
+				if len(m.Spawns) != 1 || m.Spawns[0] != (Vec2D{X: 10, Y: 44}) {
+					t.Errorf("spawns %v", m.Spawns)
+				}
+				if len(m.Pickups) != 1 || m.Pickups[0] != (Vec2D{X: 30, Y: 24}) {
+					t.Errorf("pickups %v", m.Pickups)
+				}
+				if len(m.Objectives) != 1 || m.Objectives[0] != (Vec2D{X: 50, Y: 4}) {
+					t.Errorf("objectives %v", m.Objectives)
+				}
+			},
+		},
+		{
+			name: "tiled with rectangular tiles",
+			data: `{"width": 2, "height": 2, "tilewidth": 32, "tileheight": 16, "layers": []}`,
+			err:  "square",
+		},
+		{
+			name: "tiled without a size",
+			data: `{"width": 0, "height": 2, "tilewidth": 32, "tileheight": 32, "layers": []}`,
+			err:  "invalid Tiled map",
+		},
+		{
+			name: "tiled with an unknown tile layer",
+			data: `{"width": 1, "height": 1, "tilewidth": 32, "tileheight": 32, "layers": [
+				{"name": "Water", "type": "tilelayer", "data": [1]}
+			]}`,
+			err: "unknown Tiled tile layer",
+		},
+		{
+			name: "tiled infinite map",
+			data: `{"width": 2, "height": 2, "tilewidth": 32, "tileheight": 32, "layers": [
+				{"name": "Walls", "type": "tilelayer", "chunks": []}
+			]}`,
+			err: "expected 4",
+		},
+		{
+			name: "tiled with an unknown object",
+			data: `{"width": 1, "height": 1, "tilewidth": 32, "tileheight": 32, "layers": [
+				{"name": "Markers", "type": "objectgroup", "objects": [{"type": "chest", "x": 1, "y": 1}]}
+			]}`,
+			err: "unknown Tiled object",
+		},
+		{
+			name: "tiled image layer",
+			data: `{"width": 1, "height": 1, "tilewidth": 32, "tileheight": 32, "layers": [
+				{"name": "Background", "type": "imagelayer"}
+			]}`,
+			err: "unsupported Tiled layer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseTileMap([]byte(tt.data))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want one about %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, m)
+		})
+	}
+}
+
+func TestDefaultArena(t *testing.T) {
+	for _, size := range []Vec2D{{X: 3000, Y: 2000}, {X: 200, Y: 200}, {X: 400, Y: 1000}} {
+		m := defaultArena(size.X, size.Y)
+		width, height := m.WorldSize()
+		if width != size.X || height != size.Y {
+			t.Errorf("%v arena is %vx%v", size, width, height)
+		}
+		for x := 0; x < m.Width; x++ {
+			if m.Tiles[x] != TileSolid || m.Tiles[(m.Height-1)*m.Width+x] != TileSolid {
+				t.Fatalf("%v arena has a gap in the top or bottom wall at %d", size, x)
+			}
+		}
+		for y := 0; y < m.Height; y++ {
+			if m.Tiles[y*m.Width] != TileSolid || m.Tiles[y*m.Width+m.Width-1] != TileSolid {
+				t.Fatalf("%v arena has a gap in the side walls at %d", size, y)
+			}
+		}
+	}
+}
+
+func TestCircleBlocked(t *testing.T) {
+	// A wall tile in the middle of a 5x5 map
+	m := &TileMap{Width: 5, Height: 5, TileSize: 10, Tiles: make([]int, 25)}
+	m.Tiles[2*5+2] = TileSolid
+	tests := []struct {
+		name   string
+		pos    Vec2D
+		radius float64
+		want   bool
+	}{
+		{name: "clear", pos: Vec2D{X: 15, Y: 15}, radius: 4, want: false},
+		{name: "touching the wall's side", pos: Vec2D{X: 15, Y: 25}, radius: 6, want: true},
+		{name: "just short of the wall", pos: Vec2D{X: 15, Y: 25}, radius: 5, want: false},
+		// The wall's corner is further than its sides
+		{name: "diagonal gap", pos: Vec2D{X: 15, Y: 15}, radius: 7, want: false},
+		{name: "diagonal overlap", pos: Vec2D{X: 15, Y: 15}, radius: 7.1, want: true},
+		{name: "inside the wall", pos: Vec2D{X: 25, Y: 25}, radius: 1, want: true},
+		{name: "over the edge of the map", pos: Vec2D{X: 3, Y: 15}, radius: 4, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.CircleBlocked(tt.pos, tt.radius); got != tt.want {
+				t.Errorf("CircleBlocked(%v, %v) = %v, want %v", tt.pos, tt.radius, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"golang.org/x/exp/rand"
+)
+
+const (
+	KothScoreTeam   = "team"
+	KothScorePlayer = "player"
+)
+
+// King of the hill settings
+var (
+	kothScoring        = envString("KOTH_SCORING", KothScoreTeam)
+	kothZoneCount      = envInt("KOTH_ZONES", 1)
+	kothZoneRadius     = envFloat("KOTH_ZONE_RADIUS", 150)
+	kothCaptureTime    = envDuration("KOTH_CAPTURE_TIME", 5*time.Second)
+	kothPointsPerSec   = envFloat("KOTH_POINTS_PER_SECOND", 1)
+	kothScoreLimit     = envFloat("KOTH_SCORE_LIMIT", 100)
+	kothRotateInterval = envDuration("KOTH_ROTATE_INTERVAL", 60*time.Second)
+	// Capture progress and scores change every tick while a zone is held,
+	// so they're only sent this often. Captures and contests go out at once.
+	kothUpdateEvery = envDuration("KOTH_UPDATE_EVERY", 250*time.Millisecond)
+)
+
+// Zone is a capture circle. A side (a team, or a player when scoring per
+// player) captures it by standing in it alone for kothCaptureTime, and scores
+// while it holds it uncontested.
+type Zone struct {
+	ID        int     `json:"id"`
+	Circle    Circle  `json:"circle"`
+	Owner     int     `json:"owner"`    // side holding the zone, 0 if none
+	Progress  float64 `json:"progress"` // 0..1 capture progress of the owner
+	Contested bool    `json:"contested"`
+}
+
+// Guarded by mu
+var (
+	zones       []*Zone
+	kothScores  = make(map[int]float64) // by side
+	nextRotate  time.Time
+	scoreByTeam = kothScoring == KothScoreTeam
+)
+
+func initZones() {
+	for i := 0; i < kothZoneCount; i++ {
+		zones = append(zones, &Zone{ID: i + 1})
+	}
+	rotateZones(time.Now())
+}
+
+func runKOTH() {
+	ticker := time.NewTicker(tickRate)
+	defer ticker.Stop()
+
+	sender := modeStateSender[[]Zone]{
+		every:    kothUpdateEvery,
+		snapshot: copyZones,
+		changed:  zonesChanged,
+		send:     sendZones,
+	}
+	for now := range ticker.C {
+		mu.Lock()
+		if kothRotateInterval > 0 && now.After(nextRotate) {
+			rotateZones(now)
+		}
+		if match.state == MatchPlaying {
+			for _, zone := range zones {
+				zone.update(tickRate.Seconds())
+			}
+		}
+		sender.update(now)
+		mu.Unlock()
+	}
+}
+
+// Must be called with mu held.
+func copyZones() []Zone {
+	copied := make([]Zone, len(zones))
+	for i, zone := range zones {
+		copied[i] = *zone
+	}
+	return copied
+}
+
+// zonesChanged reports whether a zone moved, changed owner or got contested.
+// Capture progress doesn't count, it changes every tick.
+func zonesChanged(sent, current []Zone) bool {
+	if len(sent) != len(current) {
+		return true
+	}
+	for i, zone := range current {
+		if zone.Circle != sent[i].Circle || zone.Owner != sent[i].Owner || zone.Contested != sent[i].Contested {
+			return true
+		}
+	}
+	return false
+}
+
+// Must be called with mu held.
+func sendZones(now time.Time) {
+	sendToMatch(Message{
+		Type: "zones_update",
+		Content: map[string]interface{}{
+			"zones":    zones,
+			"scores":   kothScores,
+			"limit":    kothScoreLimit,
+			"byTeam":   scoreByTeam,
+			"rotateIn": nextRotate.Sub(now).Seconds(),
+		},
+	})
+}
+
+// rotateZones moves every zone to a new spot, using the map's objectives when
+// it has them. Must be called with mu held.
+func rotateZones(now time.Time) {
+	spots := rand.Perm(len(arena.Objectives))
+	for i, zone := range zones {
+		var center Vec2D
+		if i < len(spots) {
+			center = arena.Objectives[spots[i]]
+		} else {
+			center = randomZoneCenter()
+		}
+		*zone = Zone{ID: zone.ID, Circle: Circle{X: center.X, Y: center.Y, Radius: kothZoneRadius}}
+	}
+	nextRotate = now.Add(kothRotateInterval)
+	log.Printf("Moved %d capture zones", len(zones))
+}
+
+func randomZoneCenter() Vec2D {
+	width, height := arena.WorldSize()
+	margin := math.Min(kothZoneRadius, math.Min(width, height)/4)
+	return Vec2D{
+		X: margin + rand.Float64()*(width-2*margin),
+		Y: margin + rand.Float64()*(height-2*margin),
+	}
+}
+
+// zoneSide is who a player captures for: their team, or themselves.
+func zoneSide(player PlayerState) int {
+	if scoreByTeam && player.Team != 0 {
+		return player.Team
+	}
+	return player.ID
+}
+
+// update advances capture progress and scoring by dt seconds. Must be called
+// with mu held.
+func (z *Zone) update(dt float64) {
+	sides := make(map[int]bool)
+	for _, id := range playerGrid.QueryCircle(z.Circle) {
+		player := latestStates[id]
+		if z.Circle.Intersects(Circle{X: player.PosX, Y: player.PosY, Radius: playerRadius}) {
+			sides[zoneSide(player)] = true
+		}
+	}
+	z.Contested = len(sides) > 1
+	if len(sides) != 1 {
+		return
+	}
+	var side int
+	for s := range sides {
+		side = s
+	}
+
+	step := dt / kothCaptureTime.Seconds()
+	if z.Owner != side {
+		// Take the zone back down to neutral before capturing it
+		z.Progress -= step
+		if z.Progress <= 0 {
+			z.Owner, z.Progress = side, 0
+		}
+		return
+	}
+	if z.Progress < 1 {
+		z.Progress = math.Min(1, z.Progress+step)
+		if z.Progress == 1 {
+			broadcast <- Message{Type: "zone_captured", Content: map[string]interface{}{"zone": z.ID, "owner": side}}
+		}
+		return
+	}
+
+	kothScores[side] += kothPointsPerSec * dt
+	if kothScores[side] >= kothScoreLimit {
+		log.Printf("Side %d won king of the hill", side)
+		broadcast <- Message{
+			Type:    "koth_won",
+			Content: map[string]interface{}{"winner": side, "byTeam": scoreByTeam},
+		}
+		if scoreByTeam {
+			endMatch(fmt.Sprintf("Team %d wins", side))
+		} else {
+			endMatch(latestStates[side].Nickname + " wins")
+		}
+		kothScores = make(map[int]float64)
+		rotateZones(time.Now())
+	}
+}